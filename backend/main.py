@@ -1,15 +1,11 @@
-from fastapi import FastAPI, UploadFile, File, HTTPException, Body
+from fastapi import FastAPI, UploadFile, File, HTTPException, Body, Form
 from fastapi.middleware.cors import CORSMiddleware
-from fastapi.responses import StreamingResponse, Response
+from fastapi.responses import Response
 
 from PyPDF2 import PdfReader
 from dotenv import load_dotenv
 from langdetect import detect, DetectorFactory
 
-from reportlab.platypus import SimpleDocTemplate, Paragraph
-from reportlab.lib.styles import getSampleStyleSheet
-
-from io import BytesIO
 from datetime import datetime
 import google.generativeai as genai
 import tempfile
@@ -84,8 +80,25 @@ def detect_language(text: str) -> str:
     except:
         return "en"
 
-def _extract_text_from_pdf_path(path: str) -> tuple[str, int]:
+def _open_pdf(path: str, password: str | None = None) -> PdfReader:
     reader = PdfReader(path)
+    if not reader.is_encrypted:
+        return reader
+
+    # PDF yang hanya dibatasi owner password terbuka dengan password kosong;
+    # password tidak pernah disimpan atau ditulis ke log
+    try:
+        decrypted = reader.decrypt(password or "")
+    except Exception:
+        decrypted = False
+    if not decrypted:
+        reason = "the password is wrong" if password else "the PDF is password protected, send its password"
+        raise HTTPException(status_code=422, detail=f"password_required: {reason}")
+    return reader
+
+
+def _extract_text_from_pdf_path(path: str, password: str | None = None) -> tuple[str, int]:
+    reader = _open_pdf(path, password)
     text = ""
 
     for page in reader.pages:
@@ -98,13 +111,30 @@ def _extract_text_from_pdf_path(path: str) -> tuple[str, int]:
     return clean_text(text), len(reader.pages)
 
 
-def extract_text_from_pdf(file: UploadFile) -> tuple[str, int]:
+def _document_text(payload: dict) -> tuple[str, int | None]:
+    # DOCX, TXT, Markdown dan HTML sudah diekstrak oleh Go API dan dikirim
+    # sebagai `text`; PDF dibaca dari `file_path`
+    text = payload.get("text") or ""
+    if text.strip():
+        return clean_text(text), None
+
+    file_path = payload.get("file_path")
+    if not file_path:
+        raise HTTPException(status_code=400, detail="file_path or text is required")
+
+    if not os.path.exists(file_path):
+        raise HTTPException(status_code=404, detail="file not found")
+
+    return _extract_text_from_pdf_path(file_path, payload.get("password"))
+
+
+def extract_text_from_pdf(file: UploadFile, password: str | None = None) -> tuple[str, int]:
     with tempfile.NamedTemporaryFile(delete=False, suffix=".pdf") as tmp:
         shutil.copyfileobj(file.file, tmp)
         path = tmp.name
 
     try:
-        return _extract_text_from_pdf_path(path)
+        return _extract_text_from_pdf_path(path, password)
     finally:
         if os.path.exists(path):
             os.remove(path)
@@ -113,7 +143,7 @@ def extract_text_from_pdf(file: UploadFile) -> tuple[str, int]:
 # GEMINI FUNCTIONS
 # =====================
 
-def summarize_with_gemini(text: str, language: str, mode: str) -> str:
+def summarize_with_gemini(text: str, language: str, mode: str, max_words: int = 0, custom_instruction: str = "", language_name: str = "") -> str:
     instructions = {
         "id": {
             "short": "Ringkas maksimal 150 kata. Jawab sepenuhnya dalam bahasa Indonesia.",
@@ -126,9 +156,31 @@ def summarize_with_gemini(text: str, language: str, mode: str) -> str:
             "detailed": "Summarize to 300–400 words. Answer fully in English."
         }
     }
+    # bahasa lain: pakai instruksi bahasa Inggris dengan bahasa target
+    if language not in instructions:
+        target = language_name or language
+        instructions[language] = {
+            m: instr.replace("Answer fully in English.", f"Answer fully in {target}.")
+            for m, instr in instructions["en"].items()
+        }
+
+    # mode custom: instruksi sudah dirender dari template oleh Go API
+    if custom_instruction:
+        instruction = custom_instruction
+    elif mode not in instructions[language]:
+        raise HTTPException(status_code=400, detail=f"mode tidak didukung: {mode}")
+    else:
+        instruction = instructions[language][mode]
+
+    if not custom_instruction and max_words and max_words > 0:
+        instruction += (
+            f" Batasi ringkasan maksimal {max_words} kata."
+            if language == "id"
+            else f" Keep the summary under {max_words} words."
+        )
 
     prompt = f"""
-{instructions[language][mode]}
+{instruction}
 
 Rules:
 - Fokus pada ide utama
@@ -141,16 +193,40 @@ Document:
     res = model.generate_content(prompt)
     return res.text.strip()
 
-def generate_takeaways(text: str, language: str) -> list:
-    prompt = (
-        "Buat 5 poin kesimpulan terpenting dalam bahasa Indonesia:"
-        if language == "id"
-        else "Create 5 key takeaways in English:"
+def structured_with_gemini(text: str, language: str, language_name: str, schema: dict) -> str:
+    # output mentah dikembalikan apa adanya; validasi skema dan retry di Go API
+    prompt = f"""
+Summarize the document as a single JSON object that matches this JSON Schema:
+{json.dumps(schema, indent=2)}
+
+Rules:
+- Write every text value in {language_name or language}
+- key_points: 3–8 main ideas, takeaways: up to 5 key conclusions
+- entities: important people, organizations, locations, dates and products
+- action_items: concrete follow-ups stated or implied in the document (empty list if none)
+- Answer with the JSON object only
+
+Document:
+{text}
+"""
+
+    res = model.generate_content(
+        prompt,
+        generation_config={"response_mime_type": "application/json"},
     )
+    return res.text.strip()
+
+def generate_takeaways(text: str, language: str, language_name: str = "") -> list:
+    if language == "id":
+        prompt = "Buat 5 poin kesimpulan terpenting dalam bahasa Indonesia:"
+    elif language == "en" or not language_name:
+        prompt = "Create 5 key takeaways in English:"
+    else:
+        prompt = f"Create 5 key takeaways in {language_name}:"
     res = model.generate_content(prompt + "\n" + text)
     return [l.strip("-• ") for l in res.text.split("\n") if l.strip()][:5]
 
-def document_stats(text: str, pages: int):
+def document_stats(text: str, pages: int | None):
     words = len(text.split())
     return {
         "pages": pages,
@@ -162,9 +238,13 @@ def document_stats(text: str, pages: int):
 # ENDPOINTS
 # =====================
 
+@app.get("/health")
+async def health():
+    return {"status": "ok"}
+
 @app.post("/preview-pdf")
-async def preview_pdf(file: UploadFile = File(...)):
-    text, _ = extract_text_from_pdf(file)
+async def preview_pdf(file: UploadFile = File(...), password: str | None = Form(None)):
+    text, _ = extract_text_from_pdf(file, password)
     return {"preview_text": text[:1000]}
 
 @app.post("/summarize-pdf")
@@ -204,34 +284,91 @@ async def summarize_pdf(
 
 @app.post("/summarize")
 async def summarize_existing_pdf(payload: dict = Body(...)):
-    file_path = payload.get("file_path")
-    mode = payload.get("mode", "detailed")
-
-    if not file_path:
-        raise HTTPException(status_code=400, detail="file_path is required")
-
-    if not os.path.exists(file_path):
-        raise HTTPException(status_code=404, detail="file not found")
+    mode = payload.get("mode") or "detailed"
+    requested_language = payload.get("language") or "auto"
+    max_words = int(payload.get("max_words") or 0)
+    custom_instruction = (payload.get("prompt") or "").strip()
+    language_name = (payload.get("language_name") or "").strip()
 
     start = time.time()
 
-    text, pages = _extract_text_from_pdf_path(file_path)
+    text, pages = _document_text(payload)
     summary_input = text[:15000]
-    language = detect_language(summary_input)
+    language = (
+        requested_language
+        if requested_language != "auto"
+        else detect_language(summary_input)
+    )
 
-    summary = summarize_with_gemini(summary_input, language, mode)
+    takeaways = []
+    if mode == "structured":
+        # takeaways sudah ada di dalam JSON terstruktur
+        schema = payload.get("schema")
+        if not isinstance(schema, dict):
+            raise HTTPException(status_code=400, detail="schema is required for structured mode")
+        summary = structured_with_gemini(summary_input, language, language_name, schema)
+    else:
+        summary = summarize_with_gemini(summary_input, language, mode, max_words, custom_instruction, language_name)
+        takeaways = generate_takeaways(summary_input, language, language_name)
     stats = document_stats(text, pages)
 
     process_time_ms = int((time.time() - start) * 1000)
 
     return {
         "summary": summary,
+        "takeaways": takeaways,
         "process_time_ms": process_time_ms,
         "stats": stats,
         "language": language,
     }
 
 
+@app.post("/takeaways")
+async def takeaways_existing_pdf(payload: dict = Body(...)):
+    requested_language = payload.get("language") or "auto"
+    language_name = (payload.get("language_name") or "").strip()
+
+    text, _ = _document_text(payload)
+    summary_input = text[:15000]
+    language = (
+        requested_language
+        if requested_language != "auto"
+        else detect_language(summary_input)
+    )
+
+    return {
+        "takeaways": generate_takeaways(summary_input, language, language_name),
+        "language": language,
+    }
+
+
+@app.post("/translate")
+async def translate_summary(payload: dict = Body(...)):
+    text = (payload.get("text") or "").strip()
+    language = (payload.get("language") or "").strip()
+    language_name = (payload.get("language_name") or language).strip()
+
+    if not text:
+        raise HTTPException(status_code=400, detail="text is required")
+    if not language:
+        raise HTTPException(status_code=400, detail="language is required")
+
+    prompt = f"""
+Translate the following summary into {language_name}.
+
+Rules:
+- Keep the structure (paragraphs, bullet points, headings)
+- Do not add or drop information
+- Answer with the translation only
+
+Summary:
+{text}
+"""
+
+    res = model.generate_content(prompt)
+    return {"text": res.text.strip(), "language": language}
+
+
 @app.post("/download-summary-txt")
 async def download_summary_txt(data: dict = Body(...)):
     summary = data.get("summary", "")
@@ -241,21 +378,3 @@ async def download_summary_txt(data: dict = Body(...)):
         headers={"Content-Disposition": "attachment; filename=summary.txt"}
     )
 
-@app.post("/download-summary-pdf")
-async def download_summary_pdf(data: dict = Body(...)):
-    summary = data.get("summary", "")
-
-    buffer = BytesIO()
-    doc = SimpleDocTemplate(buffer)
-    styles = getSampleStyleSheet()
-
-    elements = [Paragraph(line, styles["Normal"]) for line in summary.split("\n")]
-    doc.build(elements)
-    buffer.seek(0)
-
-    return StreamingResponse(
-        buffer,
-        media_type="application/pdf",
-        headers={"Content-Disposition": "attachment; filename=summary.pdf"}
-    )
-
@@ -18,11 +18,78 @@ export default function Home() {
   const [showRegenerateModal, setShowRegenerateModal] = useState(false);
   const [regeneratePdfId, setRegeneratePdfId] = useState(null);
   const [regenerateMode, setRegenerateMode] = useState("detailed");
+  // password PDF terenkripsi; hanya dikirim, tidak pernah disimpan
+  const [password, setPassword] = useState("");
+  const [regeneratePassword, setRegeneratePassword] = useState("");
   const [metadata, setMetadata] = useState(null);
   const [view, setView] = useState("upload"); // 'upload' or 'library'
   const [selectedPdfDetail, setSelectedPdfDetail] = useState(null);
   const [summaryHistory, setSummaryHistory] = useState([]);
 
+  // file di atas RESUMABLE_THRESHOLD dikirim bertahap lewat /api/uploads,
+  // sehingga koneksi yang putus cukup mengulang satu chunk
+  const MAX_FILE_BYTES = 64 * 1024 * 1024;
+  const RESUMABLE_THRESHOLD = 8 * 1024 * 1024;
+  const CHUNK_SIZE = 5 * 1024 * 1024;
+
+  const uploadResumable = async (selected) => {
+    const start = await fetch("http://localhost:8080/api/uploads", {
+      method: "POST",
+      credentials: "omit",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ filename: selected.name, size: selected.size, mode }),
+    });
+    if (!start.ok) return start;
+    const session = await start.json();
+    const url = `http://localhost:8080${session.upload_url}`;
+
+    let offset = 0;
+    let failures = 0;
+    while (offset < selected.size) {
+      const chunk = selected.slice(offset, offset + CHUNK_SIZE);
+      const digest = await crypto.subtle.digest("SHA-256", await chunk.arrayBuffer());
+      const checksum = btoa(String.fromCharCode(...new Uint8Array(digest)));
+      try {
+        const res = await fetch(url, {
+          method: "PATCH",
+          credentials: "omit",
+          headers: {
+            "Content-Type": "application/offset+octet-stream",
+            "Upload-Offset": String(offset),
+            "Upload-Checksum": `sha256 ${checksum}`,
+          },
+          body: chunk,
+        });
+        if (res.ok) {
+          offset = Number(res.headers.get("Upload-Offset"));
+          failures = 0;
+          continue;
+        }
+        const retryable = res.status === 409 || res.status >= 500 || (await res.clone().text()).startsWith("checksum_mismatch");
+        if (!retryable) return res;
+      } catch (e) {
+        console.warn("Chunk gagal dikirim, mencoba lagi", e);
+      }
+
+      failures += 1;
+      if (failures > 5) {
+        throw new Error("upload terputus berkali-kali, coba lagi nanti");
+      }
+      await new Promise((resolve) => setTimeout(resolve, 1000 * failures));
+      // lanjutkan dari offset yang sudah diterima server
+      const head = await fetch(url, { method: "HEAD", credentials: "omit" });
+      if (!head.ok) return head;
+      offset = Number(head.headers.get("Upload-Offset"));
+    }
+
+    return fetch(`${url}/complete`, {
+      method: "POST",
+      credentials: "omit",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(password ? { password } : {}),
+    });
+  };
+
   // =====================
   // HANDLE FILE UPLOAD
   // =====================
@@ -38,13 +105,14 @@ export default function Home() {
 
     if (!selectedFile) return;
 
-    const maxSizeBytes = 10 * 1024 * 1024;
-    if (selectedFile.size > maxSizeBytes) {
-      alert(`File terlalu besar! Maksimal 10MB, file Anda ${(selectedFile.size / 1024 / 1024).toFixed(1)}MB`);
+    if (selectedFile.size > MAX_FILE_BYTES) {
+      alert(`File terlalu besar! Maksimal 64MB, file Anda ${(selectedFile.size / 1024 / 1024).toFixed(1)}MB`);
       setFile(null);
       e.target.value = '';
       return;
     }
+    // preview mengirim seluruh file sekaligus; dilewati untuk file besar
+    if (selectedFile.size > RESUMABLE_THRESHOLD) return;
 
     const formData = new FormData();
     formData.append("file", selectedFile);
@@ -97,13 +165,16 @@ export default function Home() {
   // =====================
   const handleSubmit = async () => {
     if (!file) {
-      alert("Pilih file PDF dulu");
+      alert("Pilih file dokumen dulu");
       return;
     }
 
     const formData = new FormData();
     formData.append("file", file);
     formData.append("mode", mode);
+    if (password) {
+      formData.append("password", password);
+    }
 
     setLoadingView("upload");
     setLoading(true);
@@ -114,11 +185,13 @@ export default function Home() {
     setProcessTime(null);
 
     try {
-      const res = await fetch("http://localhost:8080/api/pdfs", {
-        method: "POST",
-        body: formData,
-        credentials: "omit",
-      });
+      const res = file.size > RESUMABLE_THRESHOLD
+        ? await uploadResumable(file)
+        : await fetch("http://localhost:8080/api/pdfs", {
+            method: "POST",
+            body: formData,
+            credentials: "omit",
+          });
 
       const contentType = res.headers.get("content-type") || "";
       let data = null;
@@ -140,9 +213,9 @@ export default function Home() {
       if (!res.ok) {
         let errorMsg = "Terjadi error";
         if (res.status === 413) {
-          errorMsg = "File terlalu besar! Maksimal 10MB diperbolehkan.";
+          errorMsg = "File terlalu besar! Maksimal 64MB diperbolehkan.";
         } else if (res.status === 400 && data && data.detail?.includes("too large")) {
-          errorMsg = "File terlalu besar! Maksimal 10MB diperbolehkan.";
+          errorMsg = "File terlalu besar! Maksimal 64MB diperbolehkan.";
         } else if (data && data.detail) {
           errorMsg = data.detail;
         } else if (rawText) {
@@ -188,7 +261,10 @@ export default function Home() {
         attempts += 1;
       }
 
-      if (detail && detail.summary) {
+      if (detail && detail.summary && detail.summary.status === "password_required") {
+        alert("PDF ini dilindungi password. Masukkan password yang benar lalu generate ulang ringkasannya.");
+        await loadPdfs();
+      } else if (detail && detail.summary) {
         setSummary(detail.summary.summary_text || "");
         setProcessTime(detail.summary.process_time_ms || null);
         setTakeaways(detail.summary.takeaways || []);
@@ -328,10 +404,11 @@ export default function Home() {
   const confirmRegenerate = () => {
     if (!regeneratePdfId) return;
     setShowRegenerateModal(false);
-    handleRegenerate(regeneratePdfId, regenerateMode);
+    handleRegenerate(regeneratePdfId, regenerateMode, regeneratePassword);
+    setRegeneratePassword("");
   };
 
-  const handleRegenerate = async (id, modeToUse) => {
+  const handleRegenerate = async (id, modeToUse, pdfPassword) => {
     if (!id) return;
     const finalMode = modeToUse || mode;
     setView("library");
@@ -342,8 +419,13 @@ export default function Home() {
         method: "POST",
         credentials: "omit",
         headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({ mode: finalMode }),
+        body: JSON.stringify(pdfPassword ? { mode: finalMode, password: pdfPassword } : { mode: finalMode }),
       });
+      if (res.status === 422) {
+        alert("PDF ini dilindungi password dan password yang diberikan kosong atau salah.");
+        await loadPdfs();
+        return;
+      }
       if (!res.ok) {
         alert("Gagal generate ulang ringkasan");
         return;
@@ -470,7 +552,7 @@ export default function Home() {
             <div className="space-y-6">
               <div className="bg-slate-950/35 rounded-3xl shadow-xl border border-slate-800/60 overflow-hidden">
                 <div className="bg-slate-900/40 px-6 py-4 border-b border-slate-800/60">
-                  <h2 className="text-lg font-semibold text-slate-100">Upload Dokumen PDF</h2>
+                  <h2 className="text-lg font-semibold text-slate-100">Upload Dokumen</h2>
                 </div>
 
                 <div className="p-6 space-y-5">
@@ -488,12 +570,12 @@ export default function Home() {
                           </svg>
                         )}
                       </div>
-                      <p className="text-slate-100 font-semibold mb-1.5">{file ? file.name : "Pilih file PDF"}</p>
+                      <p className="text-slate-100 font-semibold mb-1.5">{file ? file.name : "Pilih file PDF, DOCX, TXT, Markdown atau HTML"}</p>
                       <p className="text-xs text-slate-300">
-                        {file ? `✓ File siap diproses (${(file.size / 1024 / 1024).toFixed(1)}MB)` : "Klik atau drag & drop file di sini (Maks. 10MB)"}
+                        {file ? `✓ File siap diproses (${(file.size / 1024 / 1024).toFixed(1)}MB)` : "Klik atau drag & drop file di sini (Maks. 64MB)"}
                       </p>
                     </div>
-                    <input type="file" accept="application/pdf" onChange={handleFileChange} className="hidden" />
+                    <input type="file" accept=".pdf,.docx,.txt,.md,.markdown,.html,.htm" onChange={handleFileChange} className="hidden" />
                   </label>
 
                   {/* Mode Selector */}
@@ -510,6 +592,19 @@ export default function Home() {
                     </select>
                   </div>
 
+                  {/* Password PDF terenkripsi */}
+                  <div className="space-y-2">
+                    <label className="block text-sm font-semibold text-slate-100">Password PDF (opsional)</label>
+                    <input
+                      type="password"
+                      value={password}
+                      onChange={(e) => setPassword(e.target.value)}
+                      autoComplete="off"
+                      placeholder="Isi jika PDF dilindungi password"
+                      className="w-full p-3 border border-slate-700/70 rounded-xl bg-slate-950/40 text-slate-100 font-medium focus:ring-2 focus:ring-sky-500/30 focus:border-sky-500/50 outline-none transition-all shadow-sm text-sm"
+                    />
+                  </div>
+
                   {/* Submit Button */}
                   {file && (
                     <button
@@ -636,7 +731,7 @@ export default function Home() {
                       )}
 
                       {/* Download Buttons */}
-                      <div className="grid grid-cols-2 gap-3 pt-2">
+                      <div className="grid grid-cols-3 gap-3 pt-2">
                         <button onClick={() => download("http://localhost:8080/api/download/txt", "summary.txt")} className="bg-slate-900/60 hover:bg-slate-900/80 border border-slate-700/60 text-slate-100 py-3 rounded-xl font-semibold shadow-lg hover:shadow-xl transition-all duration-300 flex items-center justify-center gap-2 text-sm">
                           <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                             <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 10v6m0 0l-3-3m3 3l3-3m2 8H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
@@ -649,6 +744,12 @@ export default function Home() {
                           </svg>
                           PDF
                         </button>
+                        <button onClick={() => download("http://localhost:8080/api/download/docx", "summary.docx")} className="bg-indigo-600/90 hover:bg-indigo-600 text-white py-3 rounded-xl font-semibold shadow-lg hover:shadow-xl transition-all duration-300 flex items-center justify-center gap-2 text-sm">
+                          <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                            <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 10v6m0 0l-3-3m3 3l3-3m2 8H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
+                          </svg>
+                          DOCX
+                        </button>
                       </div>
                     </div>
                   ) : (
@@ -888,8 +989,16 @@ export default function Home() {
               <option value="detailed">📋 Detail - Penjelasan lengkap</option>
               <option value="bullet">🎯 Bullet Points - Poin-poin penting</option>
             </select>
+            <input
+              type="password"
+              value={regeneratePassword}
+              onChange={(e) => setRegeneratePassword(e.target.value)}
+              autoComplete="off"
+              placeholder="Password PDF (opsional)"
+              className="w-full p-3 border border-slate-700/70 rounded-xl bg-slate-950/40 text-slate-100 font-medium focus:ring-2 focus:ring-sky-500/30 focus:border-sky-500/50 outline-none transition-all shadow-sm mb-6 text-sm"
+            />
             <div className="flex justify-end gap-3">
-              <button onClick={() => setShowRegenerateModal(false)} className="px-4 py-2 rounded-xl border border-slate-700/70 text-slate-200 text-sm hover:bg-slate-900/40 transition-all">
+              <button onClick={() => { setShowRegenerateModal(false); setRegeneratePassword(""); }} className="px-4 py-2 rounded-xl border border-slate-700/70 text-slate-200 text-sm hover:bg-slate-900/40 transition-all">
                 Batal
               </button>
               <button onClick={confirmRegenerate} className="px-4 py-2 rounded-xl bg-sky-600/90 text-white text-sm font-semibold hover:bg-sky-600 transition-all">
@@ -1,23 +1,133 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"pdfai/go-backend/internal/config"
 	"pdfai/go-backend/internal/db"
 	httpapi "pdfai/go-backend/internal/http"
+	"pdfai/go-backend/internal/jobs"
+	"pdfai/go-backend/internal/logging"
+	"pdfai/go-backend/internal/metrics"
+	"pdfai/go-backend/internal/tracing"
 )
 
 func main() {
-	dbConn := db.New()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("load config failed", "error", err)
+		os.Exit(1)
+	}
+
+	if err := logging.Setup(os.Stderr, cfg.Log.Level); err != nil {
+		slog.Error("log setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	dbConn := db.New(cfg.Database.URL)
 	defer dbConn.Close()
 
-	handler := httpapi.NewHandler(dbConn)
+	handler := httpapi.NewHandler(dbConn, cfg)
 	mux := httpapi.NewRouter(handler)
 
-	addr := ":8080"
-	log.Printf("Go API listening on %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatalf("server error: %v", err)
+	metrics.RegisterDB(dbConn)
+	metrics.RegisterQueue(handler.Jobs.Len)
+
+	if err := handler.ResumeInterrupted(context.Background()); err != nil {
+		slog.Error("resume interrupted summaries failed", "error", err)
+	}
+
+	srv := &http.Server{
+		Addr:     cfg.Server.Addr,
+		Handler:  mux,
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go handler.WatchCancellations(watchCtx, time.Duration(cfg.Server.CancelPollInterval))
+	go handler.Heartbeat(watchCtx, time.Duration(cfg.Server.HeartbeatTimeout)/4)
+	go handler.SweepUploadSessions(watchCtx, 15*time.Minute)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Go API listening", "addr", srv.Addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	case <-ctx.Done():
 	}
-}
\ No newline at end of file
+	stop()
+
+	slog.Info("shutting down", "drain_timeout", time.Duration(cfg.Server.ShutdownTimeout).String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+
+	// stop taking new summarizations before the listener closes so that
+	// uploads racing the shutdown get a 503 instead of a half-created job
+	handler.Jobs.Close()
+	handler.ExportJobs.Close()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("http shutdown incomplete", "error", err)
+	}
+
+	unfinished, err := handler.Jobs.Wait(shutdownCtx)
+	if err != nil && len(unfinished) > 0 {
+		slog.Warn("summaries still running at shutdown deadline, cancelling and marking interrupted", "count", len(unfinished), "pdf_ids", unfinished)
+
+		// abort the outbound summarizer calls, then give the jobs a moment to return
+		handler.Jobs.CancelAll(jobs.ErrShuttingDown)
+		waitCtx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelWait()
+		if _, err := handler.Jobs.Wait(waitCtx); err != nil {
+			slog.Warn("jobs did not return after cancellation", "error", err)
+		}
+
+		markCtx, cancelMark := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelMark()
+		if err := handler.Repo.MarkSummariesInterrupted(markCtx, unfinished, "server shut down before summarization finished"); err != nil {
+			slog.Error("mark summaries interrupted failed", "error", err)
+		}
+	}
+
+	// an export that cannot finish in time is failed; it can simply be requested again
+	if _, err := handler.ExportJobs.Wait(shutdownCtx); err != nil {
+		slog.Warn("exports still running at shutdown deadline, cancelling", "export_ids", handler.ExportJobs.Running())
+		handler.ExportJobs.CancelAll(jobs.ErrShuttingDown)
+		waitCtx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelWait()
+		if _, err := handler.ExportJobs.Wait(waitCtx); err != nil {
+			slog.Warn("exports did not return after cancellation", "error", err)
+		}
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Warn("tracing shutdown failed", "error", err)
+	}
+
+	slog.Info("shutdown complete")
+}
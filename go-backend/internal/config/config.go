@@ -0,0 +1,554 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pdfai/go-backend/internal/document"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting the Go API needs at runtime.
+//
+// Values are resolved in this order, later sources winning:
+// built-in defaults, config file (YAML or TOML), environment variables,
+// command-line flags.
+type Config struct {
+	Server     ServerConfig     `yaml:"server" toml:"server" json:"server"`
+	Database   DatabaseConfig   `yaml:"database" toml:"database" json:"database"`
+	Summarizer SummarizerConfig `yaml:"summarizer" toml:"summarizer" json:"summarizer"`
+	Upload     UploadConfig     `yaml:"upload" toml:"upload" json:"upload"`
+	Export     ExportConfig     `yaml:"export" toml:"export" json:"export"`
+	Fetch      FetchConfig      `yaml:"fetch" toml:"fetch" json:"fetch"`
+	Admin      AdminConfig      `yaml:"admin" toml:"admin" json:"admin"`
+	Tracing    TracingConfig    `yaml:"tracing" toml:"tracing" json:"tracing"`
+	Log        LogConfig        `yaml:"log" toml:"log" json:"log"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr"`
+	// ShutdownTimeout bounds how long a SIGTERM waits for in-flight requests
+	// and running summarizations before they are marked interrupted.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" json:"shutdown_timeout"`
+	// InstanceID identifies this replica as the owner of the summaries it
+	// runs. Defaults to the hostname plus the process id.
+	InstanceID string `yaml:"instance_id" toml:"instance_id" json:"instance_id"`
+	// CancelPollInterval is how often a replica checks the database for
+	// summaries cancelled through another replica.
+	CancelPollInterval Duration `yaml:"cancel_poll_interval" toml:"cancel_poll_interval" json:"cancel_poll_interval"`
+	// HeartbeatTimeout is how long a running summary or export may go
+	// without a heartbeat from its replica before another replica takes it
+	// over. Heartbeats are sent four times per timeout.
+	HeartbeatTimeout Duration `yaml:"heartbeat_timeout" toml:"heartbeat_timeout" json:"heartbeat_timeout"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" toml:"url" json:"url"`
+}
+
+type SummarizerConfig struct {
+	URL     string        `yaml:"url" toml:"url" json:"url"`
+	Timeout Duration      `yaml:"timeout" toml:"timeout" json:"timeout"`
+	Breaker BreakerConfig `yaml:"breaker" toml:"breaker" json:"breaker"`
+}
+
+// BreakerConfig tunes the circuit breaker and concurrency limit that sit in
+// front of every summarizer backend.
+type BreakerConfig struct {
+	FailureThreshold int      `yaml:"failure_threshold" toml:"failure_threshold" json:"failure_threshold"`
+	OpenTimeout      Duration `yaml:"open_timeout" toml:"open_timeout" json:"open_timeout"`
+	HalfOpenRequests int      `yaml:"half_open_requests" toml:"half_open_requests" json:"half_open_requests"`
+	MaxConcurrent    int      `yaml:"max_concurrent" toml:"max_concurrent" json:"max_concurrent"`
+	QueueTimeout     Duration `yaml:"queue_timeout" toml:"queue_timeout" json:"queue_timeout"`
+}
+
+type UploadConfig struct {
+	MaxMB      int    `yaml:"max_mb" toml:"max_mb" json:"max_mb"`
+	StorageDir string `yaml:"storage_dir" toml:"storage_dir" json:"storage_dir"`
+	// QuarantineDir keeps rejected uploads that look like an attack, e.g.
+	// PDFs with JavaScript, for later inspection. They are discarded when empty.
+	QuarantineDir string `yaml:"quarantine_dir" toml:"quarantine_dir" json:"quarantine_dir"`
+	// SessionDir holds the partial files of resumable uploads, which may be
+	// up to MaxResumableMB and are sent in chunks of at most MaxMB.
+	// MaxResumableMB cannot exceed what upload validation can read,
+	// document.MaxFileBytes; left at 0 it becomes the larger of that and
+	// MaxMB.
+	SessionDir     string   `yaml:"session_dir" toml:"session_dir" json:"session_dir"`
+	SessionTTL     Duration `yaml:"session_ttl" toml:"session_ttl" json:"session_ttl"`
+	MaxResumableMB int      `yaml:"max_resumable_mb" toml:"max_resumable_mb" json:"max_resumable_mb"`
+}
+
+// ResumableMB is the size limit of resumable uploads in MB: MaxResumableMB,
+// or when that is not set, the largest file validation can read but at
+// least MaxMB.
+func (u UploadConfig) ResumableMB() int {
+	if u.MaxResumableMB > 0 {
+		return u.MaxResumableMB
+	}
+	return max(u.MaxMB, document.MaxFileBytes>>20)
+}
+
+type ExportConfig struct {
+	// StorageDir holds the archives produced by bulk export jobs.
+	StorageDir string `yaml:"storage_dir" toml:"storage_dir" json:"storage_dir"`
+}
+
+type FetchConfig struct {
+	// Timeout bounds the whole download of a document from a URL.
+	Timeout Duration `yaml:"timeout" toml:"timeout" json:"timeout"`
+	// AllowPrivate lists addresses or CIDR ranges, e.g. 10.20.0.0/16, that
+	// may be fetched even though they are private, loopback or link-local.
+	AllowPrivate []string `yaml:"allow_private" toml:"allow_private" json:"allow_private"`
+}
+
+// Allowlist parses AllowPrivate; single addresses become /32 or /128 ranges.
+func (c FetchConfig) Allowlist() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range c.AllowPrivate {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("fetch.allow_private: %q is not an address or CIDR range", s)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+type AdminConfig struct {
+	// Token guards the /api/admin endpoints. Admin endpoints are disabled when empty.
+	Token string `yaml:"token" toml:"token" json:"token"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "otlp" or "stdout".
+	Exporter string `yaml:"exporter" toml:"exporter" json:"exporter"`
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318.
+	// When empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" json:"endpoint"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" json:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" json:"sample_ratio"`
+}
+
+type LogConfig struct {
+	// Level is the initial log level; it can be changed at runtime through
+	// /api/admin/log-level.
+	Level string `yaml:"level" toml:"level" json:"level"`
+}
+
+// Duration is a time.Duration that can be read from "90s"-style strings in
+// config files and rendered back the same way.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:               ":8080",
+			ShutdownTimeout:    Duration(30 * time.Second),
+			CancelPollInterval: Duration(2 * time.Second),
+			HeartbeatTimeout:   Duration(time.Minute),
+		},
+		Summarizer: SummarizerConfig{
+			URL:     "http://localhost:8000/summarize",
+			Timeout: Duration(120 * time.Second),
+			Breaker: BreakerConfig{
+				FailureThreshold: 5,
+				OpenTimeout:      Duration(30 * time.Second),
+				HalfOpenRequests: 1,
+				MaxConcurrent:    4,
+				QueueTimeout:     Duration(30 * time.Second),
+			},
+		},
+		Upload: UploadConfig{
+			MaxMB:      10,
+			StorageDir: "storage/pdfs",
+			SessionDir: "storage/uploads",
+			SessionTTL: Duration(24 * time.Hour),
+		},
+		Export: ExportConfig{
+			StorageDir: "storage/exports",
+		},
+		Fetch: FetchConfig{
+			Timeout: Duration(30 * time.Second),
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "pdfai-go-api",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, an optional config file,
+// the environment and the given command-line arguments, then validates it.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	var (
+		configFile        = fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+		addr              = fs.String("addr", "", "listen address, e.g. :8080")
+		shutdownTimeout   = fs.Duration("shutdown-timeout", 0, "how long to drain requests and jobs on shutdown")
+		databaseURL       = fs.String("database-url", "", "PostgreSQL connection string")
+		summarizerURL     = fs.String("summarizer-url", "", "URL of the summarizer /summarize endpoint")
+		summarizerTimeout = fs.Duration("summarizer-timeout", 0, "timeout for summarizer calls")
+		maxUploadMB       = fs.Int("max-upload-mb", 0, "maximum upload size in MB")
+		storageDir        = fs.String("storage-dir", "", "directory where uploaded files are stored")
+		tracingExporter   = fs.String("tracing-exporter", "", "trace exporter: none, otlp or stdout")
+		logLevel          = fs.String("log-level", "", "log level: debug, info, warn or error")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+
+	// only flags that were explicitly set override earlier sources
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = Duration(*shutdownTimeout)
+		case "database-url":
+			cfg.Database.URL = *databaseURL
+		case "summarizer-url":
+			cfg.Summarizer.URL = *summarizerURL
+		case "summarizer-timeout":
+			cfg.Summarizer.Timeout = Duration(*summarizerTimeout)
+		case "max-upload-mb":
+			cfg.Upload.MaxMB = *maxUploadMB
+		case "storage-dir":
+			cfg.Upload.StorageDir = *storageDir
+		case "tracing-exporter":
+			cfg.Tracing.Exporter = *tracingExporter
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		}
+	})
+
+	cfg.Upload.MaxResumableMB = cfg.Upload.ResumableMB()
+
+	if cfg.Server.InstanceID == "" {
+		host, _ := os.Hostname()
+		cfg.Server.InstanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("parse YAML config %s: %w", path, err)
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), cfg); err != nil {
+			return fmt.Errorf("parse TOML config %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file type %q (use .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	if v := os.Getenv("ADDR"); v != "" {
+		cfg.Server.Addr = v
+	} else if v := os.Getenv("PORT"); v != "" {
+		cfg.Server.Addr = ":" + v
+	}
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("SHUTDOWN_TIMEOUT: %w", err)
+		}
+		cfg.Server.ShutdownTimeout = Duration(d)
+	}
+	if v := os.Getenv("HEARTBEAT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("HEARTBEAT_TIMEOUT: %w", err)
+		}
+		cfg.Server.HeartbeatTimeout = Duration(d)
+	}
+	if v := os.Getenv("INSTANCE_ID"); v != "" {
+		cfg.Server.InstanceID = v
+	}
+	if v := os.Getenv("DATABASE_URL"); v != "" {
+		cfg.Database.URL = v
+	}
+	if v := os.Getenv("SUMMARIZER_URL"); v != "" {
+		cfg.Summarizer.URL = v
+	}
+	if v := os.Getenv("SUMMARIZER_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("SUMMARIZER_TIMEOUT: %w", err)
+		}
+		cfg.Summarizer.Timeout = Duration(d)
+	}
+	if v := os.Getenv("SUMMARIZER_BREAKER_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("SUMMARIZER_BREAKER_THRESHOLD: %w", err)
+		}
+		cfg.Summarizer.Breaker.FailureThreshold = n
+	}
+	if v := os.Getenv("SUMMARIZER_BREAKER_OPEN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("SUMMARIZER_BREAKER_OPEN_TIMEOUT: %w", err)
+		}
+		cfg.Summarizer.Breaker.OpenTimeout = Duration(d)
+	}
+	if v := os.Getenv("SUMMARIZER_MAX_CONCURRENT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("SUMMARIZER_MAX_CONCURRENT: %w", err)
+		}
+		cfg.Summarizer.Breaker.MaxConcurrent = n
+	}
+	if v := os.Getenv("MAX_UPLOAD_MB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MAX_UPLOAD_MB: %w", err)
+		}
+		cfg.Upload.MaxMB = n
+	}
+	if v := os.Getenv("STORAGE_DIR"); v != "" {
+		cfg.Upload.StorageDir = v
+	}
+	if v := os.Getenv("QUARANTINE_DIR"); v != "" {
+		cfg.Upload.QuarantineDir = v
+	}
+	if v := os.Getenv("UPLOAD_SESSION_DIR"); v != "" {
+		cfg.Upload.SessionDir = v
+	}
+	if v := os.Getenv("UPLOAD_SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("UPLOAD_SESSION_TTL: %w", err)
+		}
+		cfg.Upload.SessionTTL = Duration(d)
+	}
+	if v := os.Getenv("MAX_RESUMABLE_UPLOAD_MB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MAX_RESUMABLE_UPLOAD_MB: %w", err)
+		}
+		cfg.Upload.MaxResumableMB = n
+	}
+	if v := os.Getenv("EXPORT_STORAGE_DIR"); v != "" {
+		cfg.Export.StorageDir = v
+	}
+	if v := os.Getenv("FETCH_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FETCH_TIMEOUT: %w", err)
+		}
+		cfg.Fetch.Timeout = Duration(d)
+	}
+	if v := os.Getenv("FETCH_ALLOW_PRIVATE"); v != "" {
+		cfg.Fetch.AllowPrivate = strings.Split(v, ",")
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		cfg.Admin.Token = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.Log.Level = v
+	}
+	if v := os.Getenv("TRACING_EXPORTER"); v != "" {
+		cfg.Tracing.Exporter = v
+	}
+	if v := os.Getenv("TRACING_ENDPOINT"); v != "" {
+		cfg.Tracing.Endpoint = v
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		cfg.Tracing.ServiceName = v
+	}
+	if v := os.Getenv("TRACING_SAMPLE_RATIO"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("TRACING_SAMPLE_RATIO: %w", err)
+		}
+		cfg.Tracing.SampleRatio = f
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.CancelPollInterval <= 0 {
+		errs = append(errs, errors.New("server.cancel_poll_interval must be positive"))
+	}
+	if c.Server.HeartbeatTimeout < Duration(4*time.Second) {
+		errs = append(errs, errors.New("server.heartbeat_timeout must be at least 4s"))
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url is required (DATABASE_URL)"))
+	}
+	if u, err := url.Parse(c.Summarizer.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("summarizer.url %q must be an absolute http(s) URL", c.Summarizer.URL))
+	}
+	if c.Summarizer.Timeout <= 0 {
+		errs = append(errs, errors.New("summarizer.timeout must be positive"))
+	}
+	if b := c.Summarizer.Breaker; b.FailureThreshold <= 0 || b.OpenTimeout <= 0 || b.HalfOpenRequests <= 0 || b.MaxConcurrent <= 0 || b.QueueTimeout < 0 {
+		errs = append(errs, errors.New("summarizer.breaker: threshold, open_timeout, half_open_requests and max_concurrent must be positive"))
+	}
+	maxFileMB := document.MaxFileBytes >> 20
+	if c.Upload.MaxMB <= 0 {
+		errs = append(errs, errors.New("upload.max_mb must be positive"))
+	} else if c.Upload.MaxMB > maxFileMB {
+		errs = append(errs, fmt.Errorf("upload.max_mb must be at most %d, the largest file uploads can be validated at", maxFileMB))
+	}
+	if c.Upload.StorageDir == "" {
+		errs = append(errs, errors.New("upload.storage_dir must not be empty"))
+	}
+	if c.Upload.SessionDir == "" {
+		errs = append(errs, errors.New("upload.session_dir must not be empty"))
+	}
+	if c.Upload.SessionTTL <= 0 {
+		errs = append(errs, errors.New("upload.session_ttl must be positive"))
+	}
+	if c.Upload.MaxResumableMB < 0 {
+		errs = append(errs, errors.New("upload.max_resumable_mb must not be negative"))
+	} else if c.Upload.MaxResumableMB > 0 && c.Upload.MaxResumableMB < c.Upload.MaxMB {
+		errs = append(errs, errors.New("upload.max_resumable_mb must be at least upload.max_mb"))
+	} else if c.Upload.MaxResumableMB > maxFileMB {
+		errs = append(errs, fmt.Errorf("upload.max_resumable_mb must be at most %d, the largest file uploads can be validated at", maxFileMB))
+	}
+	if c.Export.StorageDir == "" {
+		errs = append(errs, errors.New("export.storage_dir must not be empty"))
+	}
+	if c.Fetch.Timeout <= 0 {
+		errs = append(errs, errors.New("fetch.timeout must be positive"))
+	}
+	if _, err := c.Fetch.Allowlist(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q must be none, otlp or stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Redacted returns a copy that is safe to show to admins: credentials in
+// the database URL and the admin token are masked.
+func (c Config) Redacted() Config {
+	c.Database.URL = redactDSN(c.Database.URL)
+	if c.Admin.Token != "" {
+		c.Admin.Token = redactedValue
+	}
+	return c
+}
+
+// dsnPassword matches a password in a key/value DSN
+// ("host=db password='s3 cret'"), quoted or not.
+var dsnPassword = regexp.MustCompile(`(?i)(\b(?:ssl)?password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN masks the passwords in a database URL or key/value DSN. A DSN
+// that is neither is masked whole, as it cannot be told what is secret.
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil || u.Host == "" {
+			return redactedValue
+		}
+		if q := u.Query(); q.Has("password") || q.Has("sslpassword") {
+			for _, k := range []string{"password", "sslpassword"} {
+				if q.Has(k) {
+					// the same mask url.URL.Redacted puts in the userinfo
+					q.Set(k, "xxxxx")
+				}
+			}
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	if strings.Contains(dsn, "=") {
+		return dsnPassword.ReplaceAllString(dsn, "${1}"+redactedValue)
+	}
+	return redactedValue
+}
+
+const redactedValue = "[REDACTED]"
@@ -3,25 +3,27 @@ package db
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"os"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-func New() *sql.DB {
-	dsn := os.Getenv("DATABASE_URL")
+func New(dsn string) *sql.DB {
 	if dsn == "" {
-		log.Fatal("DATABASE_URL is not set")
+		slog.Error("database URL is not set")
+		os.Exit(1)
 	}
 
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
+		slog.Error("open db failed", "error", err)
+		os.Exit(1)
 	}
 
 	if err := db.PingContext(context.Background()); err != nil {
-		log.Fatalf("failed to ping db: %v", err)
+		slog.Error("ping db failed", "error", err)
+		os.Exit(1)
 	}
 
 	return db
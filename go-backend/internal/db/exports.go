@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const exportColumns = `id, status, format, include_originals, created_from, created_to, document_count, blob_key, size_bytes, error_message, created_at, updated_at, finished_at`
+
+func scanExportJob(row rowScanner) (ExportJob, error) {
+	var (
+		j            ExportJob
+		from, to     sql.NullTime
+		count        sql.NullInt32
+		blobKey      sql.NullString
+		size         sql.NullInt64
+		errorMessage sql.NullString
+		finished     sql.NullTime
+	)
+	if err := row.Scan(&j.ID, &j.Status, &j.Format, &j.IncludeOriginals, &from, &to, &count, &blobKey, &size,
+		&errorMessage, &j.CreatedAt, &j.UpdatedAt, &finished); err != nil {
+		return j, err
+	}
+	j.CreatedFrom = nullableTime(from)
+	j.CreatedTo = nullableTime(to)
+	j.FinishedAt = nullableTime(finished)
+	if count.Valid {
+		n := int(count.Int32)
+		j.DocumentCount = &n
+	}
+	if size.Valid {
+		n := size.Int64
+		j.SizeBytes = &n
+	}
+	j.BlobKey = nullableString(blobKey)
+	j.ErrorMessage = nullableString(errorMessage)
+	return j, nil
+}
+
+// prefixColumns qualifies a comma-separated column list with a table alias.
+func prefixColumns(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
+func nullableTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
+
+func (r *Repository) CreateExportJob(ctx context.Context, j ExportJob) (_ *ExportJob, err error) {
+	ctx, span := startSpan(ctx, "CreateExportJob", "insert", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	created, err := scanExportJob(r.DB.QueryRowContext(ctx, `
+		insert into export_jobs (id, status, format, include_originals, created_from, created_to)
+		values ($1, $2, $3, $4, $5, $6)
+		returning `+exportColumns,
+		j.ID, ExportPending, j.Format, j.IncludeOriginals, j.CreatedFrom, j.CreatedTo))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *Repository) GetExportJob(ctx context.Context, id string) (_ *ExportJob, err error) {
+	ctx, span := startSpan(ctx, "GetExportJob", "select", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	j, err := scanExportJob(r.DB.QueryRowContext(ctx, `select `+exportColumns+` from export_jobs where id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// StartExportJob marks an export as running on the replica owner.
+func (r *Repository) StartExportJob(ctx context.Context, id string, owner string) (err error) {
+	ctx, span := startSpan(ctx, "StartExportJob", "update", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update export_jobs
+		set status = 'running', owner = $1, updated_at = now(), heartbeat_at = now()
+		where id = $2
+	`, owner, id)
+	return err
+}
+
+// TouchExportJobs records a heartbeat for the given exports, which this
+// replica is running.
+func (r *Repository) TouchExportJobs(ctx context.Context, ids []string) (err error) {
+	ctx, span := startSpan(ctx, "TouchExportJobs", "update", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update export_jobs
+		set heartbeat_at = now()
+		where id = any($1) and status in ('pending', 'running')
+	`, ids)
+	return err
+}
+
+// FailOrphanedExportJobs fails the unfinished exports whose heartbeat is
+// older than staleAfter and, on startup, the ones still owned by owner from
+// before the restart, and returns their ids. Unlike summaries they are not
+// resumed; an export can simply be requested again.
+func (r *Repository) FailOrphanedExportJobs(ctx context.Context, owner string, staleAfter time.Duration, startup bool, errorMessage string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "FailOrphanedExportJobs", "update", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := r.DB.QueryContext(ctx, `
+		update export_jobs
+		set status = 'failed',
+		    error_message = $4,
+		    updated_at = now(),
+		    finished_at = now()
+		where status in ('pending', 'running')
+		  and (greatest(heartbeat_at, updated_at) < now() - make_interval(secs => $2)
+		       or ($3 and owner = $1))
+		returning id
+	`, owner, staleAfter.Seconds(), startup, errorMessage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// FinishExportJob records the archive of a successful export.
+func (r *Repository) FinishExportJob(ctx context.Context, id string, blobKey string, sizeBytes int64, documents int) (err error) {
+	ctx, span := startSpan(ctx, "FinishExportJob", "update", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update export_jobs
+		set status = 'success',
+		    blob_key = $1,
+		    size_bytes = $2,
+		    document_count = $3,
+		    error_message = null,
+		    updated_at = now(),
+		    finished_at = now()
+		where id = $4
+	`, blobKey, sizeBytes, documents, id)
+	return err
+}
+
+func (r *Repository) FailExportJob(ctx context.Context, id string, errorMessage string) (err error) {
+	ctx, span := startSpan(ctx, "FailExportJob", "update", "export_jobs")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update export_jobs
+		set status = 'failed',
+		    error_message = $1,
+		    updated_at = now(),
+		    finished_at = now()
+		where id = $2
+	`, errorMessage, id)
+	return err
+}
+
+// ListExportItems returns the documents uploaded in [from, to), oldest
+// first, with their current summary revisions. Nil bounds are open.
+func (r *Repository) ListExportItems(ctx context.Context, from, to *time.Time) (_ []ExportItem, err error) {
+	ctx, span := startSpan(ctx, "ListExportItems", "select", "pdf_files")
+	defer func() { endSpan(span, err) }()
+
+	const inRange = `($1::timestamptz is null or f.created_at >= $1) and ($2::timestamptz is null or f.created_at < $2)`
+
+	rows, err := r.DB.QueryContext(ctx, `
+		select f.id, f.original_name, f.stored_path, f.size_bytes, f.mime_type, f.created_at, f.updated_at
+		from pdf_files f
+		where `+inRange+`
+		order by f.created_at, f.id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		items []ExportItem
+		index = make(map[string]int)
+	)
+	for rows.Next() {
+		var f PdfFile
+		if err := rows.Scan(&f.ID, &f.OriginalName, &f.StoredPath, &f.SizeBytes, &f.MimeType, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		index[f.ID] = len(items)
+		items = append(items, ExportItem{File: f})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	revRows, err := r.DB.QueryContext(ctx, `
+		select `+prefixColumns("rv", revisionColumns)+`
+		from summary_revisions rv
+		join pdf_summaries s on s.pdf_id = rv.pdf_id and s.current_rev = rv.rev
+		join pdf_files f on f.id = rv.pdf_id
+		where `+inRange,
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer revRows.Close()
+
+	for revRows.Next() {
+		rev, err := scanRevision(revRows)
+		if err != nil {
+			return nil, err
+		}
+		// documents uploaded after the first query are left out
+		if i, ok := index[rev.PdfID]; ok {
+			items[i].Revision = &rev
+		}
+	}
+	return items, revRows.Err()
+}
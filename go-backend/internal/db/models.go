@@ -19,6 +19,118 @@ type PdfSummary struct {
 	Status        string
 	ProcessTimeMs *int
 	ErrorMessage  *string
+	Mode          *string
+	Language      *string
+	// Options is the JSON-encoded summarizer.Options the summary was requested with.
+	Options []byte
+	// CurrentRev is the summary_revisions.rev that SummaryText was taken from.
+	CurrentRev *int
+	// Structured is the JSON of the current revision when it is structured.
+	Structured []byte
+	// Takeaways are the key takeaways of the current revision.
+	Takeaways []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Revision kinds.
+const (
+	RevisionGenerated  = "generated"
+	RevisionTranslated = "translated"
+	RevisionEdited     = "edited"
+)
+
+// SummaryRevision is one immutable version of a document's summary.
+type SummaryRevision struct {
+	ID    string
+	PdfID string
+	Rev   int
+	// ParentRev is the revision this one was derived from, e.g. the source
+	// of a translation.
+	ParentRev   *int
+	Kind        string
+	SummaryText string
+	Mode        *string
+	Language    *string
+	Options     []byte
+	// Structured holds the validated JSON of a structured summary.
+	Structured []byte
+	Takeaways  []string
+	// Author is set on edited revisions.
+	Author        *string
+	ProcessTimeMs *int
 	CreatedAt     time.Time
-	UpdatedAt     time.Time
+}
+
+// SummaryTemplate is a custom summary mode. Its Name is used as the mode of
+// a summary request and Prompt is a text/template rendered per request.
+type SummaryTemplate struct {
+	ID              string
+	Name            string
+	Description     string
+	Prompt          string
+	DefaultLanguage *string
+	DefaultMaxWords *int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// Export job statuses.
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportSuccess = "success"
+	ExportFailed  = "failed"
+)
+
+// ExportJob is a bulk export of the summaries of the documents uploaded in
+// [CreatedFrom, CreatedTo) into a ZIP archive in blob storage.
+type ExportJob struct {
+	ID               string
+	Status           string
+	Format           string
+	IncludeOriginals bool
+	CreatedFrom      *time.Time
+	CreatedTo        *time.Time
+	DocumentCount    *int
+	// BlobKey names the finished archive in export storage.
+	BlobKey      *string
+	SizeBytes    *int64
+	ErrorMessage *string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	FinishedAt   *time.Time
+}
+
+// ExportItem is one document of an export with its current summary
+// revision, which is nil when the document has none.
+type ExportItem struct {
+	File     PdfFile
+	Revision *SummaryRevision
+}
+
+// Upload session statuses.
+const (
+	UploadActive     = "active"
+	UploadWriting    = "writing"
+	UploadCompleting = "completing"
+	UploadCompleted  = "completed"
+)
+
+// UploadSession is a resumable upload. Chunks are appended until Offset
+// reaches SizeBytes; completing the session creates the pdf_files row
+// recorded in PdfID.
+type UploadSession struct {
+	ID        string
+	Status    string
+	Filename  string
+	SizeBytes int64
+	Offset    int64
+	// Checksum is the hex SHA-256 of the whole file, if the client gave one.
+	Checksum  *string
+	Options   []byte
+	PdfID     *string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt time.Time
 }
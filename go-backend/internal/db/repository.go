@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Repository struct {
@@ -13,19 +16,33 @@ func NewRepository(db *sql.DB) *Repository {
 	return &Repository{DB: db}
 }
 
-func (r *Repository) CreatePdfFile(ctx context.Context, f PdfFile) error {
-	_, err := r.DB.ExecContext(ctx, `
+// nullJSON maps an empty JSON document to SQL null.
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+func (r *Repository) CreatePdfFile(ctx context.Context, f PdfFile) (err error) {
+	ctx, span := startSpan(ctx, "CreatePdfFile", "insert", "pdf_files")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
 		insert into pdf_files (id, original_name, stored_path, size_bytes, mime_type)
 		values ($1, $2, $3, $4, $5)
 	`, f.ID, f.OriginalName, f.StoredPath, f.SizeBytes, f.MimeType)
 	return err
 }
 
-func (r *Repository) CreatePdfSummaryPending(ctx context.Context, s PdfSummary) error {
-	_, err := r.DB.ExecContext(ctx, `
-		insert into pdf_summaries (id, pdf_id, status)
-		values ($1, $2, $3)
-	`, s.ID, s.PdfID, s.Status)
+func (r *Repository) CreatePdfSummaryPending(ctx context.Context, s PdfSummary) (err error) {
+	ctx, span := startSpan(ctx, "CreatePdfSummaryPending", "insert", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		insert into pdf_summaries (id, pdf_id, status, mode, language, options)
+		values ($1, $2, $3, $4, $5, $6)
+	`, s.ID, s.PdfID, s.Status, s.Mode, s.Language, nullJSON(s.Options))
 	return err
 }
 
@@ -38,7 +55,10 @@ type PdfWithSummary struct {
 	ProcessTimeMs sql.NullInt32
 }
 
-func (r *Repository) ListPdfFiles(ctx context.Context) ([]PdfWithSummary, error) {
+func (r *Repository) ListPdfFiles(ctx context.Context) (_ []PdfWithSummary, err error) {
+	ctx, span := startSpan(ctx, "ListPdfFiles", "select", "pdf_files")
+	defer func() { endSpan(span, err) }()
+
 	rows, err := r.DB.QueryContext(ctx, `
 		select f.id, f.original_name, f.size_bytes, f.created_at,
 		       s.status, s.process_time_ms
@@ -67,12 +87,17 @@ type PdfDetail struct {
 	Summary PdfSummary
 }
 
-func (r *Repository) GetPdfWithSummary(ctx context.Context, id string) (*PdfDetail, error) {
+func (r *Repository) GetPdfWithSummary(ctx context.Context, id string) (_ *PdfDetail, err error) {
+	ctx, span := startSpan(ctx, "GetPdfWithSummary", "select", "pdf_files")
+	defer func() { endSpan(span, err) }()
+
 	row := r.DB.QueryRowContext(ctx, `
 		select f.id, f.original_name, f.stored_path, f.size_bytes, f.mime_type, f.created_at, f.updated_at,
-		       s.id, s.pdf_id, s.summary_text, s.status, s.process_time_ms, s.error_message, s.created_at, s.updated_at
+		       s.id, s.pdf_id, s.summary_text, s.status, s.process_time_ms, s.error_message,
+		       s.mode, s.language, s.options, s.current_rev, rv.structured, rv.takeaways, s.created_at, s.updated_at
 		from pdf_files f
 		left join pdf_summaries s on s.pdf_id = f.id
+		left join summary_revisions rv on rv.pdf_id = s.pdf_id and rv.rev = s.current_rev
 		where f.id = $1
 	`, id)
 
@@ -85,11 +110,16 @@ func (r *Repository) GetPdfWithSummary(ctx context.Context, id string) (*PdfDeta
 		status       sql.NullString
 		processTime  sql.NullInt32
 		errorMessage sql.NullString
+		mode         sql.NullString
+		language     sql.NullString
+		currentRev   sql.NullInt32
+		takeaways    []byte
 	)
 
 	if err := row.Scan(
 		&f.ID, &f.OriginalName, &f.StoredPath, &f.SizeBytes, &f.MimeType, &f.CreatedAt, &f.UpdatedAt,
-		&s.ID, &s.PdfID, &summaryText, &status, &processTime, &errorMessage, &s.CreatedAt, &s.UpdatedAt,
+		&s.ID, &s.PdfID, &summaryText, &status, &processTime, &errorMessage,
+		&mode, &language, &s.Options, &currentRev, &s.Structured, &takeaways, &s.CreatedAt, &s.UpdatedAt,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -113,38 +143,301 @@ func (r *Repository) GetPdfWithSummary(ctx context.Context, id string) (*PdfDeta
 		msg := errorMessage.String
 		s.ErrorMessage = &msg
 	}
+	if mode.Valid {
+		m := mode.String
+		s.Mode = &m
+	}
+	if language.Valid {
+		l := language.String
+		s.Language = &l
+	}
+	if currentRev.Valid {
+		rev := int(currentRev.Int32)
+		s.CurrentRev = &rev
+	}
+	if s.Takeaways, err = decodeTakeaways(takeaways); err != nil {
+		return nil, err
+	}
 
 	return &PdfDetail{File: f, Summary: s}, nil
 }
 
-func (r *Repository) UpdateSummarySuccess(ctx context.Context, pdfID string, summary string, processTimeMs int) error {
-	_, err := r.DB.ExecContext(ctx, `
+// UpdateSummarySuccess stores a generated summary as a new revision and
+// makes it the current one. rev carries the text, language, structured
+// output and timing; mode and options are taken from the summary row. It
+// returns the new revision number, or 0 when the summary was cancelled in
+// the meantime and nothing was written.
+func (r *Repository) UpdateSummarySuccess(ctx context.Context, rev SummaryRevision) (_ int, err error) {
+	ctx, span := startSpan(ctx, "UpdateSummarySuccess", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	var n int
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		var mode sql.NullString
+		err := tx.QueryRowContext(ctx, `
+			select mode, options from pdf_summaries
+			where pdf_id = $1 and status <> 'cancelled'
+			for update
+		`, rev.PdfID).Scan(&mode, &rev.Options)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rev.ID = uuid.NewString()
+		rev.Kind = RevisionGenerated
+		rev.Mode = nullableString(mode)
+		n, err = insertRevision(ctx, tx, rev)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			update pdf_summaries
+			set summary_text = $1,
+			    status = 'success',
+			    process_time_ms = $2,
+			    current_rev = $3,
+			    error_message = null,
+			    updated_at = now()
+			where pdf_id = $4
+		`, rev.SummaryText, rev.ProcessTimeMs, n, rev.PdfID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *Repository) UpdateSummaryFailed(ctx context.Context, pdfID string, errorMessage string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateSummaryFailed", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update pdf_summaries
+		set status = 'failed',
+		    error_message = $1,
+		    updated_at = now()
+		where pdf_id = $2 and status <> 'cancelled'
+	`, errorMessage, pdfID)
+	return err
+}
+
+// ClaimSummary records owner as the replica running a pending summary. It
+// reports false when the summary is no longer pending, e.g. it was
+// cancelled before the job got to it.
+func (r *Repository) ClaimSummary(ctx context.Context, pdfID string, owner string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "ClaimSummary", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	res, err := r.DB.ExecContext(ctx, `
+		update pdf_summaries
+		set owner = $1,
+		    updated_at = now()
+		where pdf_id = $2 and status = 'pending'
+	`, owner, pdfID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// StartSummary resets a summary to pending for a new run owned by owner,
+// whatever state it was in before, and records the options of that run.
+func (r *Repository) StartSummary(ctx context.Context, pdfID string, owner string, mode string, language string, options []byte) (err error) {
+	ctx, span := startSpan(ctx, "StartSummary", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
 		update pdf_summaries
-		set summary_text = $1,
-		    status = 'success',
-		    process_time_ms = $2,
+		set status = 'pending',
+		    owner = $1,
+		    mode = $2,
+		    language = $3,
+		    options = $4,
 		    error_message = null,
+		    cancelled_at = null,
 		    updated_at = now()
-		where pdf_id = $3
-	`, summary, processTimeMs, pdfID)
+		where pdf_id = $5
+	`, owner, mode, language, nullJSON(options), pdfID)
 	return err
 }
 
-func (r *Repository) UpdateSummaryFailed(ctx context.Context, pdfID string, errorMessage string) error {
-	_, err := r.DB.ExecContext(ctx, `
+// CancelSummary marks a pending or interrupted summary as cancelled and
+// returns the replica that owns it. ok is false when there was nothing to cancel.
+func (r *Repository) CancelSummary(ctx context.Context, pdfID string) (owner string, ok bool, err error) {
+	ctx, span := startSpan(ctx, "CancelSummary", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	var o sql.NullString
+	err = r.DB.QueryRowContext(ctx, `
 		update pdf_summaries
-		set status = 'failed',
+		set status = 'cancelled',
+		    error_message = 'cancelled by user',
+		    cancelled_at = now(),
+		    updated_at = now()
+		where pdf_id = $1 and status in ('pending', 'interrupted')
+		returning owner
+	`, pdfID).Scan(&o)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return o.String, true, nil
+}
+
+// ListCancelledSummaries returns which of the given pdfs have a cancelled summary.
+func (r *Repository) ListCancelledSummaries(ctx context.Context, pdfIDs []string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ListCancelledSummaries", "select", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := r.DB.QueryContext(ctx, `
+		select pdf_id from pdf_summaries
+		where pdf_id = any($1) and status = 'cancelled'
+	`, pdfIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	return result, rows.Err()
+}
+
+// UpdateSummaryUnavailable records that the summarizer was not even tried
+// because its circuit breaker was open or it was saturated.
+func (r *Repository) UpdateSummaryUnavailable(ctx context.Context, pdfID string, errorMessage string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateSummaryUnavailable", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update pdf_summaries
+		set status = 'summarizer_unavailable',
 		    error_message = $1,
 		    updated_at = now()
-		where pdf_id = $2
+		where pdf_id = $2 and status <> 'cancelled'
 	`, errorMessage, pdfID)
 	return err
 }
 
+// UpdateSummaryPasswordRequired records that the document is an encrypted
+// PDF that could not be opened with the given password, if any. A
+// regenerate with the right password summarizes it.
+func (r *Repository) UpdateSummaryPasswordRequired(ctx context.Context, pdfID string, errorMessage string) (err error) {
+	ctx, span := startSpan(ctx, "UpdateSummaryPasswordRequired", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update pdf_summaries
+		set status = 'password_required',
+		    error_message = $1,
+		    updated_at = now()
+		where pdf_id = $2 and status <> 'cancelled'
+	`, errorMessage, pdfID)
+	return err
+}
+
+// MarkSummariesInterrupted moves pending summaries of the given pdfs to the
+// 'interrupted' status so they can be resumed on the next start.
+func (r *Repository) MarkSummariesInterrupted(ctx context.Context, pdfIDs []string, reason string) (err error) {
+	ctx, span := startSpan(ctx, "MarkSummariesInterrupted", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update pdf_summaries
+		set status = 'interrupted',
+		    error_message = $1,
+		    updated_at = now()
+		where pdf_id = any($2) and status = 'pending'
+	`, reason, pdfIDs)
+	return err
+}
+
+// TouchSummaries records a heartbeat for the pending summaries of the given
+// pdfs, which this replica is running.
+func (r *Repository) TouchSummaries(ctx context.Context, pdfIDs []string) (err error) {
+	ctx, span := startSpan(ctx, "TouchSummaries", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update pdf_summaries
+		set heartbeat_at = now()
+		where pdf_id = any($1) and status = 'pending'
+	`, pdfIDs)
+	return err
+}
+
+// ClaimOrphanedSummaries takes over the summaries other runs left behind:
+// interrupted ones, pending ones whose heartbeat is older than staleAfter
+// (their replica died) and, on startup, pending ones still owned by owner
+// from before the restart. They are put back to 'pending' under owner and
+// returned with their files and summary options. Rows are locked so two
+// replicas never claim the same one.
+func (r *Repository) ClaimOrphanedSummaries(ctx context.Context, owner string, staleAfter time.Duration, startup bool) (_ []PdfDetail, err error) {
+	ctx, span := startSpan(ctx, "ClaimOrphanedSummaries", "update", "pdf_summaries")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := r.DB.QueryContext(ctx, `
+		with claimed as (
+			update pdf_summaries
+			set status = 'pending',
+			    owner = $1,
+			    error_message = null,
+			    updated_at = now(),
+			    heartbeat_at = now()
+			where id in (
+				select id from pdf_summaries
+				where status = 'interrupted'
+				   or (status = 'pending' and (
+				        greatest(heartbeat_at, updated_at) < now() - make_interval(secs => $2)
+				        or ($3 and owner = $1)))
+				for update skip locked
+			)
+			returning pdf_id, options
+		)
+		select f.id, f.original_name, f.stored_path, f.size_bytes, f.mime_type, f.created_at, f.updated_at,
+		       c.options
+		from pdf_files f
+		join claimed c on c.pdf_id = f.id
+	`, owner, staleAfter.Seconds(), startup)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []PdfDetail
+	for rows.Next() {
+		var d PdfDetail
+		f := &d.File
+		if err := rows.Scan(&f.ID, &f.OriginalName, &f.StoredPath, &f.SizeBytes, &f.MimeType, &f.CreatedAt, &f.UpdatedAt, &d.Summary.Options); err != nil {
+			return nil, err
+		}
+		d.Summary.PdfID = f.ID
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
 // DeletePdf deletes a pdf_file (and its summaries via cascade) and returns the stored_path.
-func (r *Repository) DeletePdf(ctx context.Context, id string) (string, error) {
+func (r *Repository) DeletePdf(ctx context.Context, id string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "DeletePdf", "delete", "pdf_files")
+	defer func() { endSpan(span, err) }()
+
 	var storedPath string
-	err := r.DB.QueryRowContext(ctx, `
+	err = r.DB.QueryRowContext(ctx, `
 		select stored_path from pdf_files where id = $1
 	`, id).Scan(&storedPath)
 	if err != nil {
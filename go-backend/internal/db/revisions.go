@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrRevisionConflict is returned by ReviseCurrent when the current revision
+// is no longer the one the change was based on.
+var ErrRevisionConflict = errors.New("summary was changed by someone else")
+
+// inTx runs fn in a transaction that is committed when fn returns nil.
+func (r *Repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullableString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	v := s.String
+	return &v
+}
+
+// insertRevision numbers rev after the latest revision of its document and
+// inserts it. The caller must hold the pdf_summaries row lock of the
+// document so concurrent writers do not pick the same number.
+func insertRevision(ctx context.Context, tx *sql.Tx, rev SummaryRevision) (int, error) {
+	takeaways, err := encodeTakeaways(rev.Takeaways)
+	if err != nil {
+		return 0, err
+	}
+
+	var next int
+	if err := tx.QueryRowContext(ctx, `
+		select coalesce(max(rev), 0) + 1 from summary_revisions where pdf_id = $1
+	`, rev.PdfID).Scan(&next); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into summary_revisions (id, pdf_id, rev, parent_rev, kind, summary_text, mode, language, options, structured, takeaways, author, process_time_ms)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, rev.ID, rev.PdfID, next, rev.ParentRev, rev.Kind, rev.SummaryText, rev.Mode, rev.Language, nullJSON(rev.Options), nullJSON(rev.Structured), nullJSON(takeaways), rev.Author, rev.ProcessTimeMs)
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// AddRevision stores rev as the next revision of its document without
+// changing the current one. It returns nil when the document has no summary.
+func (r *Repository) AddRevision(ctx context.Context, rev SummaryRevision) (_ *SummaryRevision, err error) {
+	ctx, span := startSpan(ctx, "AddRevision", "insert", "summary_revisions")
+	defer func() { endSpan(span, err) }()
+
+	var created *SummaryRevision
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		var locked string
+		err := tx.QueryRowContext(ctx, `
+			select pdf_id from pdf_summaries where pdf_id = $1 for update
+		`, rev.PdfID).Scan(&locked)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if rev.ID == "" {
+			rev.ID = uuid.NewString()
+		}
+		n, err := insertRevision(ctx, tx, rev)
+		if err != nil {
+			return err
+		}
+
+		out, err := scanRevision(tx.QueryRowContext(ctx, `select `+revisionColumns+` from summary_revisions where pdf_id = $1 and rev = $2`, rev.PdfID, n))
+		if err != nil {
+			return err
+		}
+		created = &out
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ReviseCurrent stores rev as the next revision of its document and makes
+// it the current one, provided the current revision is still baseRev. It
+// returns ErrRevisionConflict otherwise, and nil when the document has no
+// summary.
+func (r *Repository) ReviseCurrent(ctx context.Context, rev SummaryRevision, baseRev int) (_ *SummaryRevision, err error) {
+	ctx, span := startSpan(ctx, "ReviseCurrent", "insert", "summary_revisions")
+	defer func() { endSpan(span, err) }()
+
+	var created *SummaryRevision
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		var current sql.NullInt32
+		err := tx.QueryRowContext(ctx, `
+			select current_rev from pdf_summaries where pdf_id = $1 for update
+		`, rev.PdfID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !current.Valid || int(current.Int32) != baseRev {
+			return ErrRevisionConflict
+		}
+
+		if rev.ID == "" {
+			rev.ID = uuid.NewString()
+		}
+		n, err := insertRevision(ctx, tx, rev)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			update pdf_summaries
+			set summary_text = $1,
+			    current_rev = $2,
+			    updated_at = now()
+			where pdf_id = $3
+		`, rev.SummaryText, n, rev.PdfID); err != nil {
+			return err
+		}
+
+		out, err := scanRevision(tx.QueryRowContext(ctx, `select `+revisionColumns+` from summary_revisions where pdf_id = $1 and rev = $2`, rev.PdfID, n))
+		if err != nil {
+			return err
+		}
+		created = &out
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+const revisionColumns = `id, pdf_id, rev, parent_rev, kind, summary_text, mode, language, options, structured, takeaways, author, process_time_ms, created_at`
+
+func encodeTakeaways(takeaways []string) ([]byte, error) {
+	if takeaways == nil {
+		return nil, nil
+	}
+	return json.Marshal(takeaways)
+}
+
+func decodeTakeaways(b []byte) ([]string, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var takeaways []string
+	if err := json.Unmarshal(b, &takeaways); err != nil {
+		return nil, err
+	}
+	return takeaways, nil
+}
+
+func scanRevision(row rowScanner) (SummaryRevision, error) {
+	var (
+		rev         SummaryRevision
+		parentRev   sql.NullInt32
+		mode        sql.NullString
+		language    sql.NullString
+		processTime sql.NullInt32
+		takeaways   []byte
+		author      sql.NullString
+	)
+	if err := row.Scan(&rev.ID, &rev.PdfID, &rev.Rev, &parentRev, &rev.Kind, &rev.SummaryText,
+		&mode, &language, &rev.Options, &rev.Structured, &takeaways, &author, &processTime, &rev.CreatedAt); err != nil {
+		return rev, err
+	}
+	var err error
+	if rev.Takeaways, err = decodeTakeaways(takeaways); err != nil {
+		return rev, err
+	}
+	if parentRev.Valid {
+		p := int(parentRev.Int32)
+		rev.ParentRev = &p
+	}
+	rev.Mode = nullableString(mode)
+	rev.Language = nullableString(language)
+	rev.Author = nullableString(author)
+	if processTime.Valid {
+		ms := int(processTime.Int32)
+		rev.ProcessTimeMs = &ms
+	}
+	return rev, nil
+}
+
+// ListRevisions returns every revision of a document, oldest first.
+func (r *Repository) ListRevisions(ctx context.Context, pdfID string) (_ []SummaryRevision, err error) {
+	ctx, span := startSpan(ctx, "ListRevisions", "select", "summary_revisions")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := r.DB.QueryContext(ctx, `
+		select `+revisionColumns+` from summary_revisions where pdf_id = $1 order by rev
+	`, pdfID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []SummaryRevision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rev)
+	}
+	return result, rows.Err()
+}
+
+// GetRevision returns nil when the document has no such revision.
+func (r *Repository) GetRevision(ctx context.Context, pdfID string, rev int) (_ *SummaryRevision, err error) {
+	ctx, span := startSpan(ctx, "GetRevision", "select", "summary_revisions")
+	defer func() { endSpan(span, err) }()
+
+	out, err := scanRevision(r.DB.QueryRowContext(ctx, `
+		select `+revisionColumns+` from summary_revisions where pdf_id = $1 and rev = $2
+	`, pdfID, rev))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &out, nil
+}
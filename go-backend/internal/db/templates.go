@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IsUniqueViolation reports whether err comes from a unique constraint.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+const templateColumns = `id, name, description, prompt, default_language, default_max_words, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTemplate(row rowScanner) (SummaryTemplate, error) {
+	var (
+		t        SummaryTemplate
+		language sql.NullString
+		maxWords sql.NullInt32
+	)
+	if err := row.Scan(&t.ID, &t.Name, &t.Description, &t.Prompt, &language, &maxWords, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return t, err
+	}
+	if language.Valid {
+		l := language.String
+		t.DefaultLanguage = &l
+	}
+	if maxWords.Valid {
+		n := int(maxWords.Int32)
+		t.DefaultMaxWords = &n
+	}
+	return t, nil
+}
+
+func (r *Repository) ListTemplates(ctx context.Context) (_ []SummaryTemplate, err error) {
+	ctx, span := startSpan(ctx, "ListTemplates", "select", "summary_templates")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := r.DB.QueryContext(ctx, `select `+templateColumns+` from summary_templates order by name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []SummaryTemplate
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// GetTemplate returns nil when no template has the given id.
+func (r *Repository) GetTemplate(ctx context.Context, id string) (_ *SummaryTemplate, err error) {
+	ctx, span := startSpan(ctx, "GetTemplate", "select", "summary_templates")
+	defer func() { endSpan(span, err) }()
+
+	t, err := scanTemplate(r.DB.QueryRowContext(ctx, `select `+templateColumns+` from summary_templates where id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTemplateByName returns nil when no template has the given name.
+func (r *Repository) GetTemplateByName(ctx context.Context, name string) (_ *SummaryTemplate, err error) {
+	ctx, span := startSpan(ctx, "GetTemplateByName", "select", "summary_templates")
+	defer func() { endSpan(span, err) }()
+
+	t, err := scanTemplate(r.DB.QueryRowContext(ctx, `select `+templateColumns+` from summary_templates where name = $1`, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *Repository) CreateTemplate(ctx context.Context, t SummaryTemplate) (_ *SummaryTemplate, err error) {
+	ctx, span := startSpan(ctx, "CreateTemplate", "insert", "summary_templates")
+	defer func() { endSpan(span, err) }()
+
+	created, err := scanTemplate(r.DB.QueryRowContext(ctx, `
+		insert into summary_templates (id, name, description, prompt, default_language, default_max_words)
+		values ($1, $2, $3, $4, $5, $6)
+		returning `+templateColumns,
+		t.ID, t.Name, t.Description, t.Prompt, t.DefaultLanguage, t.DefaultMaxWords))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateTemplate replaces every editable field and returns nil when no
+// template has t.ID.
+func (r *Repository) UpdateTemplate(ctx context.Context, t SummaryTemplate) (_ *SummaryTemplate, err error) {
+	ctx, span := startSpan(ctx, "UpdateTemplate", "update", "summary_templates")
+	defer func() { endSpan(span, err) }()
+
+	updated, err := scanTemplate(r.DB.QueryRowContext(ctx, `
+		update summary_templates
+		set name = $2, description = $3, prompt = $4,
+		    default_language = $5, default_max_words = $6, updated_at = now()
+		where id = $1
+		returning `+templateColumns,
+		t.ID, t.Name, t.Description, t.Prompt, t.DefaultLanguage, t.DefaultMaxWords))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteTemplate reports whether a template was deleted. Summaries created
+// with it keep their resolved prompt in options.
+func (r *Repository) DeleteTemplate(ctx context.Context, id string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "DeleteTemplate", "delete", "summary_templates")
+	defer func() { endSpan(span, err) }()
+
+	res, err := r.DB.ExecContext(ctx, `delete from summary_templates where id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const uploadColumns = `id, status, filename, size_bytes, offset_bytes, checksum, options, pdf_id, created_at, updated_at, expires_at`
+
+func scanUploadSession(row rowScanner) (UploadSession, error) {
+	var (
+		s        UploadSession
+		checksum sql.NullString
+		pdfID    sql.NullString
+	)
+	if err := row.Scan(&s.ID, &s.Status, &s.Filename, &s.SizeBytes, &s.Offset, &checksum, &s.Options, &pdfID,
+		&s.CreatedAt, &s.UpdatedAt, &s.ExpiresAt); err != nil {
+		return s, err
+	}
+	s.Checksum = nullableString(checksum)
+	s.PdfID = nullableString(pdfID)
+	return s, nil
+}
+
+func (r *Repository) CreateUploadSession(ctx context.Context, s UploadSession) (_ *UploadSession, err error) {
+	ctx, span := startSpan(ctx, "CreateUploadSession", "insert", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	created, err := scanUploadSession(r.DB.QueryRowContext(ctx, `
+		insert into upload_sessions (id, status, filename, size_bytes, checksum, options, expires_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning `+uploadColumns,
+		s.ID, UploadActive, s.Filename, s.SizeBytes, s.Checksum, s.Options, s.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *Repository) GetUploadSession(ctx context.Context, id string) (_ *UploadSession, err error) {
+	ctx, span := startSpan(ctx, "GetUploadSession", "select", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	s, err := scanUploadSession(r.DB.QueryRowContext(ctx, `select `+uploadColumns+` from upload_sessions where id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ClaimUploadWrite lets writer write the chunk at offset of an active
+// session by moving it to 'writing'. A claim older than staleAfter is taken
+// over, as its writer gave up or died. It reports false when the session is
+// not at offset or another chunk is being written.
+func (r *Repository) ClaimUploadWrite(ctx context.Context, id string, offset int64, writer string, staleAfter time.Duration) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "ClaimUploadWrite", "update", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	res, err := r.DB.ExecContext(ctx, `
+		update upload_sessions
+		set status = 'writing', writer = $1, updated_at = now()
+		where id = $2 and offset_bytes = $3
+		  and (status = 'active'
+		       or (status = 'writing' and updated_at < now() - make_interval(secs => $4)))
+	`, writer, id, offset, staleAfter.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseUploadWrite ends the claim of writer, records the offset reached
+// and makes the session active again. It reports false when the claim was
+// lost, i.e. the session was deleted or taken over.
+func (r *Repository) ReleaseUploadWrite(ctx context.Context, id string, writer string, offset int64) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "ReleaseUploadWrite", "update", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	res, err := r.DB.ExecContext(ctx, `
+		update upload_sessions
+		set status = 'active', writer = null, offset_bytes = $1, updated_at = now()
+		where id = $2 and status = 'writing' and writer = $3
+	`, offset, id, writer)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimUploadSession moves a fully received session to 'completing' so only
+// one request turns it into a document. It reports false when the session
+// is incomplete or already being completed.
+func (r *Repository) ClaimUploadSession(ctx context.Context, id string) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "ClaimUploadSession", "update", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	res, err := r.DB.ExecContext(ctx, `
+		update upload_sessions
+		set status = 'completing', updated_at = now()
+		where id = $1 and status = 'active' and offset_bytes = size_bytes
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseUploadSession returns a claimed session to 'active' after its
+// completion failed, so it can be completed again.
+func (r *Repository) ReleaseUploadSession(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "ReleaseUploadSession", "update", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update upload_sessions
+		set status = 'active', updated_at = now()
+		where id = $1 and status = 'completing'
+	`, id)
+	return err
+}
+
+func (r *Repository) CompleteUploadSession(ctx context.Context, id, pdfID string) (err error) {
+	ctx, span := startSpan(ctx, "CompleteUploadSession", "update", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `
+		update upload_sessions
+		set status = 'completed', pdf_id = $1, updated_at = now()
+		where id = $2
+	`, pdfID, id)
+	return err
+}
+
+func (r *Repository) DeleteUploadSession(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteUploadSession", "delete", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	_, err = r.DB.ExecContext(ctx, `delete from upload_sessions where id = $1`, id)
+	return err
+}
+
+// DeleteExpiredUploadSessions removes the sessions past their expiry and
+// returns their ids. Sessions being written or completed are left alone
+// unless that was abandoned an hour ago.
+func (r *Repository) DeleteExpiredUploadSessions(ctx context.Context) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "DeleteExpiredUploadSessions", "delete", "upload_sessions")
+	defer func() { endSpan(span, err) }()
+
+	rows, err := r.DB.QueryContext(ctx, `
+		delete from upload_sessions
+		where expires_at < now()
+		  and (status not in ('writing', 'completing') or updated_at < now() - interval '1 hour')
+		returning id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,359 @@
+// Package diff compares two texts word by word or sentence by sentence
+// using Myers' O(ND) algorithm, and renders the result as hunks or as a
+// unified diff.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Granularity selects the unit texts are compared in.
+type Granularity string
+
+const (
+	Words     Granularity = "word"
+	Sentences Granularity = "sentence"
+)
+
+// ParseGranularity accepts "word" and "sentence"; empty means sentence.
+func ParseGranularity(s string) (Granularity, error) {
+	switch Granularity(strings.ToLower(strings.TrimSpace(s))) {
+	case "", Sentences:
+		return Sentences, nil
+	case Words:
+		return Words, nil
+	}
+	return "", fmt.Errorf("unsupported granularity %q (use word or sentence)", s)
+}
+
+// Split breaks text into the units of g. Words are separated by
+// whitespace; sentences end at '.', '!', '?' followed by whitespace, or at
+// a line break so list items stay separate.
+func Split(text string, g Granularity) []string {
+	if g == Words {
+		return strings.Fields(text)
+	}
+
+	var (
+		units []string
+		cur   strings.Builder
+	)
+	flush := func() {
+		if s := strings.Join(strings.Fields(cur.String()), " "); s != "" {
+			units = append(units, s)
+		}
+		cur.Reset()
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == '\n' {
+			flush()
+			continue
+		}
+		cur.WriteRune(r)
+		if r == '.' || r == '!' || r == '?' || r == '…' {
+			if i+1 == len(runes) || unicode.IsSpace(runes[i+1]) {
+				flush()
+			}
+		}
+	}
+	flush()
+	return units
+}
+
+type Kind string
+
+const (
+	Equal  Kind = "equal"
+	Insert Kind = "insert"
+	Delete Kind = "delete"
+)
+
+// Op is a run of units that are equal, only in the new text (Insert) or
+// only in the old text (Delete).
+type Op struct {
+	Kind   Kind
+	Tokens []string
+}
+
+// maxEdits bounds the number of edits Myers' algorithm looks for. Texts
+// that differ more than that are reported as one replacement, which is
+// what such a diff would amount to anyway.
+const maxEdits = 2000
+
+// Compute returns the shortest edit script turning a into b.
+func Compute(a, b []string) []Op {
+	var out opList
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	out.add(Equal, a[:prefix]...)
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if ops, ok := myers(midA, midB); ok {
+		for _, op := range ops {
+			out.add(op.Kind, op.Tokens...)
+		}
+	} else {
+		out.add(Delete, midA...)
+		out.add(Insert, midB...)
+	}
+	out.add(Equal, a[len(a)-suffix:]...)
+	return out
+}
+
+// opList appends tokens, merging runs of the same kind.
+type opList []Op
+
+func (l *opList) add(kind Kind, tokens ...string) {
+	if len(tokens) == 0 {
+		return
+	}
+	if n := len(*l); n > 0 && (*l)[n-1].Kind == kind {
+		(*l)[n-1].Tokens = append((*l)[n-1].Tokens, tokens...)
+		return
+	}
+	*l = append(*l, Op{Kind: kind, Tokens: append([]string(nil), tokens...)})
+}
+
+// myers runs the greedy forward search and backtracks through the saved
+// frontier of every round. ok is false when more than maxEdits edits
+// would be needed.
+func myers(a, b []string) (_ []Op, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil, true
+	}
+
+	limit := min(n+m, maxEdits)
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d][k+d] is the furthest x reached on diagonal k after round d
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1] // down: insert b[y]
+			} else {
+				x = v[off+k-1] + 1 // right: delete a[x]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+				return backtrack(trace, a, b), true
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+	return nil, false
+}
+
+func backtrack(trace [][]int, a, b []string) []Op {
+	var rev []Op // built from the end
+	push := func(kind Kind, token string) {
+		rev = append(rev, Op{Kind: kind, Tokens: []string{token}})
+	}
+
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		// the snake after the edit
+		startX := prevX
+		if prevK == k-1 {
+			startX++
+		}
+		for x > startX {
+			x--
+			y--
+			push(Equal, a[x])
+		}
+
+		if prevK == k+1 {
+			push(Insert, b[prevY])
+		} else {
+			push(Delete, a[prevX])
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		push(Equal, a[x])
+	}
+
+	var out opList
+	for i := len(rev) - 1; i >= 0; i-- {
+		out.add(rev[i].Kind, rev[i].Tokens...)
+	}
+	return out
+}
+
+// Stats counts units per kind.
+type Stats struct {
+	Insertions int `json:"insertions"`
+	Deletions  int `json:"deletions"`
+	Unchanged  int `json:"unchanged"`
+}
+
+func Count(ops []Op) Stats {
+	var s Stats
+	for _, op := range ops {
+		switch op.Kind {
+		case Insert:
+			s.Insertions += len(op.Tokens)
+		case Delete:
+			s.Deletions += len(op.Tokens)
+		default:
+			s.Unchanged += len(op.Tokens)
+		}
+	}
+	return s
+}
+
+// Hunk is a group of nearby changes with some unchanged context. Starts are
+// 1-based unit positions as in a unified diff.
+type Hunk struct {
+	FromStart int  `json:"from_start"`
+	FromCount int  `json:"from_count"`
+	ToStart   int  `json:"to_start"`
+	ToCount   int  `json:"to_count"`
+	Ops       []Op `json:"-"`
+}
+
+type line struct {
+	kind  Kind
+	token string
+}
+
+// Hunks groups the changes of ops, keeping context unchanged units around
+// each group. Changes closer than 2*context units share a hunk.
+func Hunks(ops []Op, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	var lines []line
+	for _, op := range ops {
+		for _, t := range op.Tokens {
+			lines = append(lines, line{op.Kind, t})
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].kind == Equal {
+			i++
+			continue
+		}
+
+		// extend over changes and short equal gaps
+		start := max(0, i-context)
+		end := i
+		for end < len(lines) {
+			if lines[end].kind != Equal {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(lines) && lines[gap].kind == Equal {
+				gap++
+			}
+			if gap == len(lines) || gap-end > 2*context {
+				break
+			}
+			end = gap
+		}
+		stop := min(len(lines), end+context)
+		hunks = append(hunks, newHunk(lines, start, stop))
+		i = stop
+	}
+	return hunks
+}
+
+func newHunk(lines []line, start, stop int) Hunk {
+	// positions of lines[start] in both texts
+	fromPos, toPos := 0, 0
+	for _, l := range lines[:start] {
+		if l.kind != Insert {
+			fromPos++
+		}
+		if l.kind != Delete {
+			toPos++
+		}
+	}
+
+	h := Hunk{FromStart: fromPos + 1, ToStart: toPos + 1}
+	var ops opList
+	for _, l := range lines[start:stop] {
+		if l.kind != Insert {
+			h.FromCount++
+		}
+		if l.kind != Delete {
+			h.ToCount++
+		}
+		ops.add(l.kind, l.token)
+	}
+	if h.FromCount == 0 {
+		h.FromStart--
+	}
+	if h.ToCount == 0 {
+		h.ToStart--
+	}
+	h.Ops = ops
+	return h
+}
+
+// Unified renders hunks as a unified diff with one unit per line.
+func Unified(hunks []Hunk, fromName, toName string) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.FromStart, h.FromCount, h.ToStart, h.ToCount)
+		for _, op := range h.Ops {
+			prefix := " "
+			switch op.Kind {
+			case Insert:
+				prefix = "+"
+			case Delete:
+				prefix = "-"
+			}
+			for _, t := range op.Tokens {
+				b.WriteString(prefix + t + "\n")
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+// Package document knows which file types can be uploaded and how to get
+// their text. PDFs are read by the summarizer service itself; every other
+// type is extracted here and sent to it as plain text.
+package document
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnreadable is returned by Extract for files that are damaged or not
+	// of the type their extension claims.
+	ErrUnreadable = errors.New("document could not be read")
+
+	// ErrNoText is returned by Extract for documents without any text.
+	ErrNoText = errors.New("document contains no text")
+)
+
+var errTooLarge = fmt.Errorf("%w: it is too large to extract", ErrUnreadable)
+
+// maxExtractBytes caps how much a single document may expand to while it is
+// read, so that a small zip bomb cannot exhaust memory.
+const maxExtractBytes = 64 << 20
+
+// MaxFileBytes is the size of the largest file Validate and Extract can
+// read. Bigger uploads are refused before they are received.
+const MaxFileBytes = maxExtractBytes
+
+// Type is an uploadable document type.
+type Type struct {
+	Name     string
+	Label    string
+	MimeType string
+	// Extensions are lower-case and include the dot; the first one is used
+	// for stored files.
+	Extensions []string
+	// Extract returns the text of a document of this type. It is nil for
+	// PDFs.
+	Extract func(r io.ReaderAt, size int64) (string, error)
+	// Validate checks an upload before it is stored. Types without it are
+	// validated by extracting their text.
+	Validate func(r io.ReaderAt, size int64) error
+}
+
+// Extension is the extension stored files of this type get.
+func (t Type) Extension() string {
+	return t.Extensions[0]
+}
+
+// ExtractedHere reports whether the text is extracted by the API rather
+// than by the summarizer service.
+func (t Type) ExtractedHere() bool {
+	return t.Extract != nil
+}
+
+var types = []Type{
+	{Name: "pdf", Label: "PDF", MimeType: "application/pdf", Extensions: []string{".pdf"}, Validate: CheckPDF},
+	{Name: "docx", Label: "DOCX", MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Extensions: []string{".docx"}, Extract: DOCX},
+	{Name: "txt", Label: "TXT", MimeType: "text/plain", Extensions: []string{".txt", ".text"}, Extract: Text},
+	{Name: "md", Label: "Markdown", MimeType: "text/markdown", Extensions: []string{".md", ".markdown"}, Extract: Markdown},
+	{Name: "html", Label: "HTML", MimeType: "text/html", Extensions: []string{".html", ".htm"}, Extract: HTML},
+}
+
+// Lookup returns the type of a file by the extension of its name.
+func Lookup(filename string) (Type, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, t := range types {
+		for _, e := range t.Extensions {
+			if e == ext {
+				return t, true
+			}
+		}
+	}
+	return Type{}, false
+}
+
+// mimeAliases are other media types servers send for the supported types.
+var mimeAliases = map[string]string{
+	"application/x-pdf":     "application/pdf",
+	"text/x-markdown":       "text/markdown",
+	"application/xhtml+xml": "text/html",
+}
+
+// LookupMIME returns the type of a document by its media type, e.g. the
+// Content-Type of a download without parameters.
+func LookupMIME(mediaType string) (Type, bool) {
+	mediaType = strings.ToLower(mediaType)
+	if alias, ok := mimeAliases[mediaType]; ok {
+		mediaType = alias
+	}
+	for _, t := range types {
+		if t.MimeType == mediaType {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
+
+// Labels lists the supported types for error messages, e.g.
+// "PDF, DOCX, TXT, Markdown or HTML".
+func Labels() string {
+	labels := make([]string, len(types))
+	for i, t := range types {
+		labels[i] = t.Label
+	}
+	return strings.Join(labels[:len(labels)-1], ", ") + " or " + labels[len(labels)-1]
+}
+
+// ExtractFile extracts the text of the stored file at path.
+func ExtractFile(t Type, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return t.Extract(f, info.Size())
+}
+
+// readAll reads a whole document, failing once it exceeds maxExtractBytes.
+func readAll(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxExtractBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxExtractBytes {
+		return nil, errTooLarge
+	}
+	return data, nil
+}
+
+// tidy trims trailing spaces, drops leading and trailing blank lines and
+// keeps at most one blank line between paragraphs.
+func tidy(text string) (string, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(strings.ReplaceAll(line, "\u00a0", " "), " \t\r\f\v")
+		if strings.TrimSpace(line) == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	if len(out) == 0 {
+		return "", ErrNoText
+	}
+	return strings.Join(out, "\n"), nil
+}
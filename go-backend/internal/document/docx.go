@@ -0,0 +1,125 @@
+package document
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// wordprocessingML is the namespace of the elements of word/document.xml.
+const wordprocessingML = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+// DOCX extracts the body text of a Word document, one paragraph per line.
+// Tracked deletions and field codes are left out; tables come out one row
+// per line with tab-separated cells.
+func DOCX(r io.ReaderAt, size int64) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("%w: not a DOCX file", ErrUnreadable)
+	}
+	var body *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			body = f
+			break
+		}
+	}
+	if body == nil {
+		return "", fmt.Errorf("%w: word/document.xml is missing", ErrUnreadable)
+	}
+	rc, err := body.Open()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	defer rc.Close()
+
+	text, err := docxText(io.LimitReader(rc, maxExtractBytes+1))
+	if err != nil {
+		return "", err
+	}
+	return tidy(text)
+}
+
+func docxText(r io.Reader) (string, error) {
+	var (
+		b      strings.Builder
+		read   int
+		inText bool
+		// depth inside w:del / w:instrText, whose text is not shown
+		hidden int
+		// cells seen in the current table row
+		cells []int
+	)
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrUnreadable, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordprocessingML {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "del", "instrText", "delText":
+				hidden++
+			case "tab":
+				if hidden == 0 {
+					b.WriteByte('\t')
+				}
+			case "br", "cr":
+				if hidden == 0 {
+					b.WriteByte('\n')
+				}
+			case "tr":
+				cells = append(cells, 0)
+			case "tc":
+				if n := len(cells); n > 0 {
+					if cells[n-1] > 0 {
+						b.WriteByte('\t')
+					}
+					cells[n-1]++
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordprocessingML {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "del", "instrText", "delText":
+				hidden--
+			case "p":
+				// paragraphs inside a table cell stay on the row's line
+				if len(cells) == 0 || cells[len(cells)-1] == 0 {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(' ')
+				}
+			case "tr":
+				cells = cells[:len(cells)-1]
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText && hidden == 0 {
+				read += len(t)
+				if read > maxExtractBytes {
+					return "", errTooLarge
+				}
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,178 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// skippedElements hold no readable text.
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Math: true, atom.Iframe: true,
+	atom.Object: true, atom.Canvas: true, atom.Select: true, atom.Button: true,
+}
+
+// blockElements start a new line.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+// HTML extracts the visible text of an HTML page, starting with its title.
+// The encoding is taken from a byte order mark or <meta charset> and
+// defaults to UTF-8.
+func HTML(r io.ReaderAt, size int64) (string, error) {
+	data, err := readAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 && !bytes.HasPrefix(data, []byte{0xff, 0xfe}) && !bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+		return "", fmt.Errorf("%w: not an HTML file", ErrUnreadable)
+	}
+	decoded, err := charset.NewReader(bytes.NewReader(data), "text/html")
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	doc, err := html.Parse(decoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+
+	var b strings.Builder
+	if title := findElement(doc, atom.Title); title != nil {
+		if t := strings.Join(strings.Fields(nodeText(title)), " "); t != "" {
+			b.WriteString(t + "\n\n")
+		}
+	}
+	writeHTMLText(&b, doc, false)
+	return tidy(b.String())
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
+
+// writeHTMLText writes the text below n. Whitespace is collapsed as a
+// browser would, except inside <pre>.
+func writeHTMLText(b *strings.Builder, n *html.Node, pre bool) {
+	switch n.Type {
+	case html.TextNode:
+		if pre {
+			b.WriteString(n.Data)
+			return
+		}
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" {
+			if n.Data != "" && !endsWithSpace(b) {
+				b.WriteByte(' ')
+			}
+			return
+		}
+		if startsWithSpace(n.Data) && !endsWithSpace(b) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(text)
+		if endsWithSpaceString(n.Data) {
+			b.WriteByte(' ')
+		}
+		return
+	case html.ElementNode:
+		if skippedElements[n.DataAtom] || hidden(n) {
+			return
+		}
+		if n.DataAtom == atom.Img {
+			if alt := attr(n, "alt"); strings.TrimSpace(alt) != "" {
+				b.WriteString(strings.TrimSpace(alt) + " ")
+			}
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+	if block {
+		startLine(b)
+	}
+	if n.Type == html.ElementNode && n.DataAtom == atom.Li {
+		b.WriteString("- ")
+	}
+	pre = pre || (n.Type == html.ElementNode && n.DataAtom == atom.Pre)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeHTMLText(b, c, pre)
+		if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+			b.WriteByte('\t')
+		}
+	}
+	if block {
+		startLine(b)
+	}
+}
+
+// startLine ends the current line unless it is empty.
+func startLine(b *strings.Builder) {
+	if s := b.String(); s != "" && s[len(s)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+}
+
+func hidden(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Key == "hidden" || (a.Key == "aria-hidden" && a.Val == "true") {
+			return true
+		}
+		if a.Key == "style" && strings.Contains(strings.ReplaceAll(strings.ToLower(a.Val), " ", ""), "display:none") {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\n\r\f", rune(s[0]))
+}
+
+func endsWithSpaceString(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\n\r\f", rune(s[len(s)-1]))
+}
+
+func endsWithSpace(b *strings.Builder) bool {
+	s := b.String()
+	return s == "" || endsWithSpaceString(s)
+}
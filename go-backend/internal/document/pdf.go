@@ -0,0 +1,594 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RejectError is returned by Validate for uploads that are refused. Code is
+// a stable identifier for clients, e.g. "pdf_javascript".
+type RejectError struct {
+	Code   string
+	Reason string
+	// Suspicious files may be an attack rather than a mistake; they are
+	// kept in quarantine when that is configured.
+	Suspicious bool
+}
+
+func (e *RejectError) Error() string {
+	return e.Code + ": " + e.Reason
+}
+
+// PDFInfo is what InspectPDF found out about a PDF.
+type PDFInfo struct {
+	Version      string
+	Encrypted    bool
+	JavaScript   bool
+	LaunchAction bool
+	// ObjectStreams is set when objects are kept in compressed object
+	// streams, which encryption hides from the active content scan.
+	ObjectStreams bool
+}
+
+// Check refuses PDFs that carry active content. Encrypted PDFs pass as long
+// as they can be scanned, which their dictionaries can: they are decrypted
+// when the text is extracted, with the password given on upload or
+// regenerate. Encrypted object streams cannot be scanned, so such files are
+// refused as unverifiable.
+func (i PDFInfo) Check() error {
+	switch {
+	case i.Encrypted && i.ObjectStreams:
+		return &RejectError{Code: "pdf_unverifiable", Reason: "the PDF is encrypted and keeps objects in compressed streams, so it cannot be checked for active content", Suspicious: true}
+	case i.JavaScript:
+		return &RejectError{Code: "pdf_javascript", Reason: "the PDF contains embedded JavaScript", Suspicious: true}
+	case i.LaunchAction:
+		return &RejectError{Code: "pdf_launch_action", Reason: "the PDF contains a launch action that starts other programs", Suspicious: true}
+	}
+	return nil
+}
+
+// CheckPDF validates an uploaded PDF; see InspectPDF and PDFInfo.Check.
+func CheckPDF(r io.ReaderAt, size int64) error {
+	info, err := InspectPDF(r, size)
+	if err != nil {
+		return err
+	}
+	return info.Check()
+}
+
+const (
+	// maxInflateBytes caps how far a single object stream is inflated.
+	maxInflateBytes = 16 << 20
+	// maxXrefSections bounds the /Prev chain of incrementally updated files.
+	maxXrefSections = 256
+)
+
+// InspectPDF checks that a file really is a PDF, meaning a %PDF- header and
+// a readable cross-reference table or stream with its trailer, and looks
+// for encryption and active content: JavaScript and launch actions, also
+// inside compressed object streams. The file is read into memory.
+func InspectPDF(r io.ReaderAt, size int64) (PDFInfo, error) {
+	var info PDFInfo
+	data, err := readAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return info, err
+	}
+
+	// the header may follow a little junk, as readers accept it within 1 KB
+	start := bytes.Index(data[:min(len(data), 1024)], []byte("%PDF-"))
+	if start < 0 {
+		return info, &RejectError{Code: "not_a_pdf", Reason: "the file does not have a PDF header", Suspicious: true}
+	}
+	version := data[start+5:]
+	n := 0
+	for n < len(version) && n < 4 && (isDigit(version[n]) || version[n] == '.') {
+		n++
+	}
+	info.Version = string(version[:n])
+	if info.Version == "" {
+		return info, malformed("the PDF header has no version")
+	}
+
+	trailers, err := readTrailers(data, start)
+	if err != nil {
+		return info, malformed(err.Error())
+	}
+	hasRoot := false
+	for _, t := range trailers {
+		if _, ok := t["Encrypt"]; ok {
+			info.Encrypted = true
+		}
+		if _, ok := t["Root"]; ok {
+			hasRoot = true
+		}
+	}
+	if !hasRoot {
+		return info, malformed("the trailer does not name a document catalog")
+	}
+
+	scanActiveContent(data[start:], &info)
+	return info, nil
+}
+
+func malformed(reason string) error {
+	return &RejectError{Code: "pdf_malformed", Reason: reason}
+}
+
+// readTrailers follows the startxref offset and the /Prev chain and returns
+// the trailer dictionaries, newest first. Offsets are tried from the start
+// of the file and, for files with junk before the header, from the header.
+// Files whose offsets are broken are still accepted when a trailer can be
+// found by scanning, since every common reader repairs them that way.
+func readTrailers(data []byte, header int) ([]pdfDict, error) {
+	tail := data[max(0, len(data)-64<<10):]
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, errors.New("the file has no startxref; it may be truncated")
+	}
+	if !bytes.Contains(tail[i:], []byte("%%EOF")) {
+		return nil, errors.New("the file has no %%EOF marker; it may be truncated")
+	}
+	l := &pdfLexer{data: tail, pos: i + len("startxref")}
+	off, ok := l.integer()
+	if !ok {
+		return nil, errors.New("startxref is not followed by an offset")
+	}
+
+	var trailers []pdfDict
+	seen := make(map[int]bool)
+	for len(trailers) < maxXrefSections && !seen[off] {
+		seen[off] = true
+		t, err := readXrefSection(data, off)
+		if err != nil && header > 0 {
+			t, err = readXrefSection(data, off+header)
+		}
+		if err != nil {
+			break
+		}
+		trailers = append(trailers, t)
+		prev, ok := t.integer("Prev")
+		if !ok {
+			break
+		}
+		off = prev
+	}
+	if len(trailers) > 0 {
+		return trailers, nil
+	}
+
+	if t, ok := findTrailer(data); ok {
+		return []pdfDict{t}, nil
+	}
+	return nil, errors.New("no cross-reference table or trailer could be read")
+}
+
+// readXrefSection reads the classic xref table or the xref stream at off
+// and returns its trailer dictionary.
+func readXrefSection(data []byte, off int) (pdfDict, error) {
+	if off < 0 || off >= len(data) {
+		return nil, errors.New("xref offset is outside the file")
+	}
+	l := &pdfLexer{data: data, pos: off}
+	l.skipSpace()
+
+	if l.keyword("xref") {
+		for {
+			l.skipSpace()
+			if l.keyword("trailer") {
+				l.skipSpace()
+				return l.dict()
+			}
+			first, ok1 := l.integer()
+			count, ok2 := l.integer()
+			if !ok1 || !ok2 || first < 0 || count < 0 || count > len(data)/18 {
+				return nil, errors.New("invalid xref subsection")
+			}
+			for range count {
+				_, ok1 := l.integer()
+				_, ok2 := l.integer()
+				l.skipSpace()
+				if !ok1 || !ok2 || !(l.keyword("n") || l.keyword("f")) {
+					return nil, errors.New("invalid xref entry")
+				}
+			}
+		}
+	}
+
+	// PDF 1.5 cross-reference stream: "N G obj << /Type /XRef ... >> stream"
+	if _, ok := l.integer(); !ok {
+		return nil, errors.New("xref offset does not point at a cross-reference section")
+	}
+	if _, ok := l.integer(); !ok {
+		return nil, errors.New("xref offset does not point at a cross-reference section")
+	}
+	l.skipSpace()
+	if !l.keyword("obj") {
+		return nil, errors.New("xref offset does not point at a cross-reference section")
+	}
+	l.skipSpace()
+	d, err := l.dict()
+	if err != nil {
+		return nil, err
+	}
+	if d.name("Type") != "XRef" {
+		return nil, errors.New("xref offset does not point at a cross-reference stream")
+	}
+	return d, nil
+}
+
+// findTrailer looks for the last readable trailer dictionary, of either
+// kind, when the xref offsets cannot be trusted.
+func findTrailer(data []byte) (pdfDict, bool) {
+	for end := len(data); end > 0; {
+		i := bytes.LastIndex(data[:end], []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		l := &pdfLexer{data: data, pos: i + len("trailer")}
+		l.skipSpace()
+		if d, err := l.dict(); err == nil {
+			return d, true
+		}
+		end = i
+	}
+	for end := len(data); end > 0; {
+		i := bytes.LastIndex(data[:end], []byte("/XRef"))
+		if i < 0 {
+			break
+		}
+		if j := bytes.LastIndex(data[:i], []byte("<<")); j >= 0 {
+			l := &pdfLexer{data: data, pos: j}
+			if d, err := l.dict(); err == nil && d.name("Type") == "XRef" {
+				return d, true
+			}
+		}
+		end = i
+	}
+	return nil, false
+}
+
+// scanActiveContent looks for the names of JavaScript and launch actions in
+// the objects of the file and in its compressed object streams. Other
+// stream data is skipped: it is binary and would produce false matches.
+func scanActiveContent(data []byte, info *PDFInfo) {
+	pos := 0
+	for pos < len(data) {
+		i := indexStreamKeyword(data, pos)
+		if i < 0 {
+			scanNames(data[pos:], info)
+			return
+		}
+		objects := data[pos:i]
+		scanNames(objects, info)
+
+		bodyStart := i + len("stream")
+		if bytes.HasPrefix(data[bodyStart:], []byte("\r\n")) {
+			bodyStart += 2
+		} else if bodyStart < len(data) && (data[bodyStart] == '\n' || data[bodyStart] == '\r') {
+			bodyStart++
+		}
+		end := bytes.Index(data[bodyStart:], []byte("endstream"))
+		if end < 0 {
+			return
+		}
+		body := data[bodyStart : bodyStart+end]
+
+		// the stream dictionary is what follows the last "obj" keyword
+		dict := objects
+		if j := bytes.LastIndex(objects, []byte("obj")); j >= 0 {
+			dict = objects[j:]
+		}
+		if hasName(dict, "ObjStm") {
+			info.ObjectStreams = true
+		}
+		// an encrypted object stream does not inflate; Check refuses it
+		if !info.Encrypted && streamIs(dict, "ObjStm") {
+			if inflated, ok := inflate(body); ok {
+				scanNames(inflated, info)
+			}
+		}
+		pos = bodyStart + end + len("endstream")
+	}
+}
+
+// indexStreamKeyword finds the next "stream" keyword, which is not part of
+// "endstream".
+func indexStreamKeyword(data []byte, pos int) int {
+	for pos < len(data) {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			return -1
+		}
+		i += pos
+		if i < 3 || !bytes.Equal(data[i-3:i], []byte("end")) {
+			return i
+		}
+		pos = i + len("stream")
+	}
+	return -1
+}
+
+func streamIs(dict []byte, streamType string) bool {
+	found, flate := false, false
+	forEachName(dict, func(name string) {
+		switch name {
+		case streamType:
+			found = true
+		case "FlateDecode", "Fl":
+			flate = true
+		}
+	})
+	return found && flate
+}
+
+func hasName(dict []byte, name string) bool {
+	found := false
+	forEachName(dict, func(n string) {
+		if n == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func inflate(body []byte) ([]byte, bool) {
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+	defer zr.Close()
+	// a damaged stream still yields the objects before the damage
+	out, _ := io.ReadAll(io.LimitReader(zr, maxInflateBytes))
+	return out, len(out) > 0
+}
+
+func scanNames(data []byte, info *PDFInfo) {
+	forEachName(data, func(name string) {
+		switch name {
+		case "JS", "JavaScript":
+			info.JavaScript = true
+		case "Launch":
+			info.LaunchAction = true
+		}
+	})
+}
+
+// forEachName calls fn with every name token in data, with #xx escapes
+// decoded, so that "/J#61vaScript" is seen as "JavaScript".
+func forEachName(data []byte, fn func(string)) {
+	for pos := 0; pos < len(data); {
+		i := bytes.IndexByte(data[pos:], '/')
+		if i < 0 {
+			return
+		}
+		l := &pdfLexer{data: data, pos: pos + i}
+		fn(l.name())
+		pos = l.pos
+	}
+}
+
+// pdfDict is a parsed dictionary whose values are kept as raw text.
+type pdfDict map[string]string
+
+func (d pdfDict) integer(key string) (int, bool) {
+	n, err := strconv.Atoi(d[key])
+	return n, err == nil
+}
+
+func (d pdfDict) name(key string) string {
+	v := d[key]
+	if len(v) < 2 || v[0] != '/' {
+		return ""
+	}
+	l := &pdfLexer{data: []byte(v)}
+	return l.name()
+}
+
+// pdfLexer reads just enough PDF syntax to walk cross-reference sections
+// and dictionaries.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// keyword consumes kw if it is the next token.
+func (l *pdfLexer) keyword(kw string) bool {
+	end := l.pos + len(kw)
+	if end > len(l.data) || string(l.data[l.pos:end]) != kw {
+		return false
+	}
+	if end < len(l.data) && !isPDFSpace(l.data[end]) && !isPDFDelimiter(l.data[end]) {
+		return false
+	}
+	l.pos = end
+	return true
+}
+
+func (l *pdfLexer) integer() (int, bool) {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && isDigit(l.data[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start || l.pos-start > 12 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(l.data[start:l.pos]))
+	return n, err == nil
+}
+
+// name reads the name at l.pos, which is the "/".
+func (l *pdfLexer) name() string {
+	l.pos++
+	var b []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) || isPDFDelimiter(c) {
+			break
+		}
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+	return string(b)
+}
+
+// dict reads the dictionary at l.pos.
+func (l *pdfLexer) dict() (pdfDict, error) {
+	return l.dictDepth(0)
+}
+
+const maxPDFNesting = 64
+
+func (l *pdfLexer) dictDepth(depth int) (pdfDict, error) {
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("<<")) {
+		return nil, errors.New("expected a dictionary")
+	}
+	if depth > maxPDFNesting {
+		return nil, errors.New("objects are nested too deeply")
+	}
+	l.pos += 2
+	d := make(pdfDict)
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return nil, errors.New("unterminated dictionary")
+		}
+		if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+			l.pos += 2
+			return d, nil
+		}
+		if l.data[l.pos] != '/' {
+			return nil, fmt.Errorf("expected a name at offset %d", l.pos)
+		}
+		key := l.name()
+		l.skipSpace()
+		start := l.pos
+		if err := l.skipValue(depth); err != nil {
+			return nil, err
+		}
+		d[key] = string(bytes.TrimSpace(l.data[start:l.pos]))
+	}
+}
+
+// skipValue moves past one object: a dictionary, array, string, name,
+// number, keyword or indirect reference.
+func (l *pdfLexer) skipValue(depth int) error {
+	if l.pos >= len(l.data) {
+		return errors.New("unexpected end of file")
+	}
+	switch c := l.data[l.pos]; {
+	case bytes.HasPrefix(l.data[l.pos:], []byte("<<")):
+		_, err := l.dictDepth(depth + 1)
+		return err
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return errors.New("unterminated hex string")
+		}
+		l.pos += end + 1
+	case c == '[':
+		if depth > maxPDFNesting {
+			return errors.New("objects are nested too deeply")
+		}
+		l.pos++
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return errors.New("unterminated array")
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return nil
+			}
+			if err := l.skipValue(depth + 1); err != nil {
+				return err
+			}
+		}
+	case c == '(':
+		return l.skipString()
+	case c == '/':
+		l.name()
+	case isPDFDelimiter(c):
+		return fmt.Errorf("unexpected %q at offset %d", c, l.pos)
+	default:
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		// "12 0 R" is one value
+		if isDigit(l.data[start]) {
+			save := l.pos
+			if _, ok := l.integer(); ok {
+				l.skipSpace()
+				if l.keyword("R") {
+					return nil
+				}
+			}
+			l.pos = save
+		}
+	}
+	return nil
+}
+
+func (l *pdfLexer) skipString() error {
+	depth := 0
+	for l.pos < len(l.data) {
+		switch l.data[l.pos] {
+		case '\\':
+			l.pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				l.pos++
+				return nil
+			}
+		}
+		l.pos++
+	}
+	return errors.New("unterminated string")
+}
@@ -0,0 +1,164 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Text extracts a plain-text file. UTF-8 and UTF-16 with a byte order mark
+// are decoded; other files that are not valid UTF-8 are read as Windows-1252.
+func Text(r io.ReaderAt, size int64) (string, error) {
+	data, err := readAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return "", err
+	}
+	text, err := decodeText(data)
+	if err != nil {
+		return "", err
+	}
+	return tidy(text)
+}
+
+func decodeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return decodeUTF16(data[2:], false), nil
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return decodeUTF16(data[2:], true), nil
+	}
+	// text files do not contain NUL bytes; executables and images do
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%w: not a text file", ErrUnreadable)
+	}
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	return string(decoded), nil
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+var (
+	mdFence      = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeading    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	mdHeadingEnd = regexp.MustCompile(`\s+#+\s*$`)
+	mdUnderline  = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	mdRule       = regexp.MustCompile(`^\s{0,3}([-*_]\s*){3,}$`)
+	mdQuote      = regexp.MustCompile(`^\s{0,3}(>\s?)+`)
+	mdTableRule  = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)+\s*:?-*:?\s*$`)
+	mdRefDef     = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s+\S`)
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdRefLink    = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	mdAutolink   = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	mdStrong     = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdEmphasis   = regexp.MustCompile(`(^|[\s(])[*_](\S(?:[^*_]*?\S)?)[*_]([\s).,;:!?]|$)`)
+	mdStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdCode       = regexp.MustCompile("`+([^`]+)`+")
+	mdTag        = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+// Markdown extracts the text of a Markdown file: the markup is removed,
+// while list items and the contents of code blocks are kept as lines.
+func Markdown(r io.ReaderAt, size int64) (string, error) {
+	data, err := readAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return "", err
+	}
+	text, err := decodeText(data)
+	if err != nil {
+		return "", err
+	}
+	return tidy(stripMarkdown(text))
+}
+
+func stripMarkdown(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	lines = skipFrontMatter(lines)
+
+	var b strings.Builder
+	inFence := false
+	for _, line := range lines {
+		if mdFence.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			var keep bool
+			if line, keep = stripMarkdownLine(line); !keep {
+				continue
+			}
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// skipFrontMatter drops a YAML front matter block at the top of the file.
+func skipFrontMatter(lines []string) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// stripMarkdownLine removes the markup of one line. Lines that are nothing
+// but markup, such as table rules and link definitions, are dropped.
+func stripMarkdownLine(line string) (string, bool) {
+	switch {
+	case mdTableRule.MatchString(line), mdRefDef.MatchString(line):
+		return "", false
+	case mdRule.MatchString(line), mdUnderline.MatchString(line):
+		return "", true
+	}
+	line = mdQuote.ReplaceAllString(line, "")
+	if mdHeading.MatchString(line) {
+		line = mdHeadingEnd.ReplaceAllString(mdHeading.ReplaceAllString(line, ""), "")
+	}
+	if t := strings.TrimSpace(line); strings.HasPrefix(t, "|") && strings.HasSuffix(t, "|") {
+		cells := strings.Split(strings.Trim(t, "|"), "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		line = strings.Join(cells, "\t")
+	}
+	line = mdImage.ReplaceAllString(line, "$1")
+	line = mdLink.ReplaceAllString(line, "$1")
+	line = mdRefLink.ReplaceAllString(line, "$1")
+	line = mdAutolink.ReplaceAllString(line, "$1")
+	line = mdCode.ReplaceAllString(line, "$1")
+	line = mdStrong.ReplaceAllString(line, "$2")
+	line = mdStrike.ReplaceAllString(line, "$1")
+	line = mdEmphasis.ReplaceAllString(line, "$1$2$3")
+	line = mdTag.ReplaceAllString(line, "")
+	return line, true
+}
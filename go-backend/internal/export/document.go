@@ -0,0 +1,200 @@
+// Package export turns stored summaries into downloadable documents.
+package export
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Document is a summary ready for export, independent of the output format.
+type Document struct {
+	// ID and Rev identify the stored summary revision, when there is one.
+	ID    string
+	Rev   int
+	Title string
+	// Metadata is shown on the title page in this order, e.g. the source
+	// file, revision and language.
+	Metadata []Field
+	Summary  string
+	// Structured is the JSON of a structured summary.
+	Structured json.RawMessage
+	// Stats, when set, are listed with the metadata.
+	Stats *Stats
+	// Takeaways are rendered after the summary unless TakeawaysInline is
+	// set, which structured summaries do because their text lists them.
+	Takeaways       []string
+	TakeawaysInline bool
+	Author          string
+	Language        string
+	CreatedAt       time.Time
+}
+
+type Field struct {
+	Label string
+	Value string
+}
+
+// Fields is the metadata followed by the stats.
+func (d Document) Fields() []Field {
+	fields := append([]Field(nil), d.Metadata...)
+	if d.Stats != nil {
+		fields = append(fields, d.Stats.Fields()...)
+	}
+	return fields
+}
+
+// Stats describe the length of a summary.
+type Stats struct {
+	Words          int `json:"words"`
+	Characters     int `json:"characters"`
+	Sentences      int `json:"sentences"`
+	ReadingMinutes int `json:"reading_minutes"`
+}
+
+// wordsPerMinute is an average silent reading speed.
+const wordsPerMinute = 200
+
+func ComputeStats(text string) Stats {
+	s := Stats{
+		Words:      len(strings.Fields(text)),
+		Characters: len([]rune(strings.TrimSpace(text))),
+	}
+	runes := []rune(text)
+	for i, r := range runes {
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			s.Sentences++
+		}
+	}
+	if s.Sentences == 0 && s.Words > 0 {
+		s.Sentences = 1
+	}
+	if s.Words > 0 {
+		s.ReadingMinutes = (s.Words + wordsPerMinute - 1) / wordsPerMinute
+	}
+	return s
+}
+
+func (s Stats) Fields() []Field {
+	return []Field{
+		{Label: "Words", Value: strconv.Itoa(s.Words)},
+		{Label: "Characters", Value: strconv.Itoa(s.Characters)},
+		{Label: "Sentences", Value: strconv.Itoa(s.Sentences)},
+		{Label: "Reading time", Value: strconv.Itoa(s.ReadingMinutes) + " min"},
+	}
+}
+
+// Text is the summary followed by its takeaways as plain text.
+func (d Document) Text() string {
+	if len(d.Takeaways) == 0 || d.TakeawaysInline {
+		return d.Summary
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(d.Summary, "\n"))
+	b.WriteString("\n\nKey takeaways:\n")
+	for _, t := range d.Takeaways {
+		b.WriteString("- " + t + "\n")
+	}
+	return b.String()
+}
+
+type BlockKind int
+
+const (
+	Paragraph BlockKind = iota
+	Heading
+	Bullet
+	Numbered
+)
+
+// Block is one element of a summary's body. Marker is the number of a
+// Numbered item, e.g. "3.".
+type Block struct {
+	Kind   BlockKind
+	Text   string
+	Marker string
+}
+
+// Number is the number of a Numbered item, at least 1.
+func (b Block) Number() int {
+	n, _ := strconv.Atoi(strings.TrimRight(b.Marker, ".)"))
+	return max(n, 1)
+}
+
+var (
+	numberedRe = regexp.MustCompile(`^(\d{1,3}[.)])\s+(.*)$`)
+	emphasisRe = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+)
+
+// Blocks splits the summary, and the takeaways when they are not inline,
+// into headings, list items and paragraphs. Summaries are loosely formatted
+// model output, so Markdown headings, bold lines and short lines ending in a
+// colon all count as headings.
+func (d Document) Blocks() []Block {
+	blocks := parseBlocks(d.Summary)
+	if len(d.Takeaways) > 0 && !d.TakeawaysInline {
+		blocks = append(blocks, Block{Kind: Heading, Text: "Key takeaways"})
+		for _, t := range d.Takeaways {
+			blocks = append(blocks, Block{Kind: Bullet, Text: plainInline(t)})
+		}
+	}
+	return blocks
+}
+
+func parseBlocks(text string) []Block {
+	var (
+		blocks []Block
+		para   []string
+	)
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, Block{Kind: Paragraph, Text: plainInline(strings.Join(para, " "))})
+			para = nil
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#"):
+			flush()
+			blocks = append(blocks, Block{Kind: Heading, Text: plainInline(strings.TrimSpace(strings.TrimLeft(line, "#")))})
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "), strings.HasPrefix(line, "• "):
+			flush()
+			_, item, _ := strings.Cut(line, " ")
+			blocks = append(blocks, Block{Kind: Bullet, Text: plainInline(strings.TrimSpace(item))})
+		case numberedRe.MatchString(line):
+			flush()
+			m := numberedRe.FindStringSubmatch(line)
+			blocks = append(blocks, Block{Kind: Numbered, Marker: m[1], Text: plainInline(m[2])})
+		case isHeadingLine(line):
+			flush()
+			blocks = append(blocks, Block{Kind: Heading, Text: strings.TrimSuffix(plainInline(line), ":")})
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+func isHeadingLine(line string) bool {
+	if len([]rune(line)) > 80 {
+		return false
+	}
+	if strings.HasPrefix(line, "**") && strings.HasSuffix(strings.TrimSuffix(line, ":"), "**") {
+		return true
+	}
+	return strings.HasSuffix(line, ":") && !strings.ContainsAny(strings.TrimSuffix(line, ":"), ".!?:")
+}
+
+// plainInline drops Markdown emphasis markers.
+func plainInline(s string) string {
+	return emphasisRe.ReplaceAllString(s, "$1$2")
+}
@@ -0,0 +1,224 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const docxContentTypes = xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>` +
+	`</Types>`
+
+const docxPackageRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>` +
+	`</Relationships>`
+
+const docxDocumentRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>` +
+	`</Relationships>`
+
+const wordNS = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+// docxStyles defines the paragraph styles used by the writer; %s is the
+// default language.
+const docxStyles = xmlHeader + `<w:styles xmlns:w="` + wordNS + `">` +
+	`<w:docDefaults><w:rPrDefault><w:rPr>` +
+	`<w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Calibri" w:cs="Calibri"/>` +
+	`<w:sz w:val="22"/><w:szCs w:val="22"/><w:lang w:val="%s"/>` +
+	`</w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault>` +
+	`</w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="240"/></w:pPr><w:rPr><w:b/><w:sz w:val="48"/><w:szCs w:val="48"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="80"/><w:outlineLvl w:val="0"/></w:pPr>` +
+	`<w:rPr><w:b/><w:color w:val="1F4E79"/><w:sz w:val="28"/><w:szCs w:val="28"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Metadata"><w:name w:val="Metadata"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="0"/></w:pPr><w:rPr><w:color w:val="595959"/><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="60"/><w:ind w:left="720"/><w:contextualSpacing/></w:pPr></w:style>` +
+	`</w:styles>`
+
+// bulletNumID is the numbering instance shared by all bullet lists; every
+// numbered list gets its own instance after it so numbering restarts.
+const bulletNumID = 1
+
+// DOCX renders d as a Word document with the title, metadata, headings,
+// paragraphs and lists, and fills in the document properties.
+func DOCX(d Document) ([]byte, error) {
+	modified := d.CreatedAt
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	language := d.Language
+	if language == "" || language == "auto" {
+		language = "en-US"
+	}
+
+	var (
+		body   strings.Builder
+		lists  []int // start numbers of the numbered lists, in order
+		inList bool
+	)
+	docxParagraph(&body, "Title", 0, d.Title)
+	for _, f := range d.Fields() {
+		body.WriteString(`<w:p><w:pPr><w:pStyle w:val="Metadata"/></w:pPr>`)
+		docxRun(&body, f.Label+": ", true)
+		docxRun(&body, f.Value, false)
+		body.WriteString(`</w:p>`)
+	}
+	for _, b := range d.Blocks() {
+		switch b.Kind {
+		case Heading:
+			docxParagraph(&body, "Heading1", 0, b.Text)
+		case Bullet:
+			docxParagraph(&body, "ListParagraph", bulletNumID, b.Text)
+		case Numbered:
+			if !inList {
+				lists = append(lists, b.Number())
+			}
+			docxParagraph(&body, "ListParagraph", bulletNumID+len(lists), b.Text)
+		default:
+			docxParagraph(&body, "", 0, b.Text)
+		}
+		inList = b.Kind == Numbered
+	}
+
+	document := xmlHeader + `<w:document xmlns:w="` + wordNS + `"><w:body>` + body.String() +
+		`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/>` +
+		`<w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/>` +
+		`</w:sectPr></w:body></w:document>`
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"docProps/core.xml", docxCoreProperties(d, language, modified)},
+		{"docProps/app.xml", docxAppProperties(d)},
+		{"word/_rels/document.xml.rels", docxDocumentRels},
+		{"word/document.xml", document},
+		{"word/styles.xml", fmt.Sprintf(docxStyles, xmlEscape(language))},
+		{"word/numbering.xml", docxNumbering(lists)},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, p := range parts {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: p.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// docxParagraph writes a paragraph in style (none for Normal), as an item
+// of numbering instance numID when it is not 0.
+func docxParagraph(b *strings.Builder, style string, numID int, text string) {
+	b.WriteString(`<w:p>`)
+	if style != "" || numID != 0 {
+		b.WriteString(`<w:pPr>`)
+		if style != "" {
+			b.WriteString(`<w:pStyle w:val="` + style + `"/>`)
+		}
+		if numID != 0 {
+			fmt.Fprintf(b, `<w:numPr><w:ilvl w:val="0"/><w:numId w:val="%d"/></w:numPr>`, numID)
+		}
+		b.WriteString(`</w:pPr>`)
+	}
+	docxRun(b, text, false)
+	b.WriteString(`</w:p>`)
+}
+
+func docxRun(b *strings.Builder, text string, bold bool) {
+	b.WriteString(`<w:r>`)
+	if bold {
+		b.WriteString(`<w:rPr><w:b/></w:rPr>`)
+	}
+	b.WriteString(`<w:t xml:space="preserve">` + xmlEscape(text) + `</w:t></w:r>`)
+}
+
+// docxNumbering defines a bullet list and one decimal list per entry of
+// starts, each beginning at its start number.
+func docxNumbering(starts []int) string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<w:numbering xmlns:w="` + wordNS + `">`)
+	b.WriteString(`<w:abstractNum w:abstractNumId="0"><w:multiLevelType w:val="singleLevel"/>` +
+		`<w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="•"/><w:lvlJc w:val="left"/>` +
+		`<w:pPr><w:ind w:left="720" w:hanging="360"/></w:pPr></w:lvl></w:abstractNum>`)
+	b.WriteString(`<w:abstractNum w:abstractNumId="1"><w:multiLevelType w:val="singleLevel"/>` +
+		`<w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%1."/><w:lvlJc w:val="left"/>` +
+		`<w:pPr><w:ind w:left="720" w:hanging="360"/></w:pPr></w:lvl></w:abstractNum>`)
+	fmt.Fprintf(&b, `<w:num w:numId="%d"><w:abstractNumId w:val="0"/></w:num>`, bulletNumID)
+	for i, start := range starts {
+		fmt.Fprintf(&b, `<w:num w:numId="%d"><w:abstractNumId w:val="1"/>`+
+			`<w:lvlOverride w:ilvl="0"><w:startOverride w:val="%d"/></w:lvlOverride></w:num>`, bulletNumID+1+i, start)
+	}
+	b.WriteString(`</w:numbering>`)
+	return b.String()
+}
+
+func docxCoreProperties(d Document, language string, modified time.Time) string {
+	creator := d.Author
+	if creator == "" {
+		creator = Creator
+	}
+	stamp := modified.UTC().Format(time.RFC3339)
+
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<cp:coreProperties` +
+		` xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:dcterms="http://purl.org/dc/terms/"` +
+		` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`)
+	b.WriteString(`<dc:title>` + xmlEscape(d.Title) + `</dc:title>`)
+	b.WriteString(`<dc:subject>Summary</dc:subject>`)
+	b.WriteString(`<dc:creator>` + xmlEscape(creator) + `</dc:creator>`)
+	b.WriteString(`<cp:keywords>summary</cp:keywords>`)
+	b.WriteString(`<dc:language>` + xmlEscape(language) + `</dc:language>`)
+	if d.Rev > 0 {
+		fmt.Fprintf(&b, `<cp:revision>%d</cp:revision>`, d.Rev)
+	}
+	if d.ID != "" {
+		b.WriteString(`<dc:identifier>` + xmlEscape(d.ID) + `</dc:identifier>`)
+	}
+	b.WriteString(`<dcterms:created xsi:type="dcterms:W3CDTF">` + stamp + `</dcterms:created>`)
+	b.WriteString(`<dcterms:modified xsi:type="dcterms:W3CDTF">` + stamp + `</dcterms:modified>`)
+	b.WriteString(`</cp:coreProperties>`)
+	return b.String()
+}
+
+func docxAppProperties(d Document) string {
+	stats := ComputeStats(d.Text())
+	return xmlHeader + `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">` +
+		`<Application>` + Creator + `</Application>` +
+		fmt.Sprintf(`<Words>%d</Words><Characters>%d</Characters>`, stats.Words, stats.Characters) +
+		`</Properties>`
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,217 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDOCX(t *testing.T) {
+	d := Document{
+		ID:    "3f1c0a52-8f0e-4b7e-9a55-4c1f2e6d7a10",
+		Rev:   2,
+		Title: "Quarterly report",
+		Metadata: []Field{
+			{Label: "Source", Value: "report.pdf"},
+			{Label: "Language", Value: "en"},
+		},
+		Summary: "## Overview\n" +
+			"Revenue grew by 12% & costs fell.\n\n" +
+			"Risks:\n" +
+			"- Supply <chain> delays\n" +
+			"- Currency swings\n\n" +
+			"3. Hire two engineers\n" +
+			"4. Close the Q3 audit\n",
+		Takeaways: []string{"Growth is **steady**"},
+		Author:    "Finance team",
+		Language:  "en-GB",
+		CreatedAt: time.Date(2025, 3, 14, 9, 30, 0, 0, time.UTC),
+	}
+
+	out, err := DOCX(d)
+	if err != nil {
+		t.Fatalf("DOCX: %v", err)
+	}
+	parts := unzipParts(t, out)
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml", "docProps/core.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("part %s is missing", name)
+		}
+	}
+	for name, content := range parts {
+		if err := checkXML(content); err != nil {
+			t.Errorf("part %s is not well-formed XML: %v", name, err)
+		}
+	}
+
+	var types struct {
+		Overrides []struct {
+			PartName string `xml:"PartName,attr"`
+		} `xml:"Override"`
+	}
+	decodePart(t, parts, "[Content_Types].xml", &types)
+	for _, o := range types.Overrides {
+		if _, ok := parts[strings.TrimPrefix(o.PartName, "/")]; !ok {
+			t.Errorf("[Content_Types].xml names %s, which is not in the package", o.PartName)
+		}
+	}
+
+	var rels struct {
+		Relationships []struct {
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	decodePart(t, parts, "_rels/.rels", &rels)
+	for _, r := range rels.Relationships {
+		if _, ok := parts[r.Target]; !ok {
+			t.Errorf("_rels/.rels points at %s, which is not in the package", r.Target)
+		}
+	}
+
+	var document struct {
+		Paragraphs []docxTestParagraph `xml:"body>p"`
+	}
+	decodePart(t, parts, "word/document.xml", &document)
+
+	want := []struct {
+		style string
+		numID string
+		text  string
+	}{
+		{"Title", "", "Quarterly report"},
+		{"Metadata", "", "Source: report.pdf"},
+		{"Metadata", "", "Language: en"},
+		{"Heading1", "", "Overview"},
+		{"", "", "Revenue grew by 12% & costs fell."},
+		{"Heading1", "", "Risks"},
+		{"ListParagraph", "1", "Supply <chain> delays"},
+		{"ListParagraph", "1", "Currency swings"},
+		{"ListParagraph", "2", "Hire two engineers"},
+		{"ListParagraph", "2", "Close the Q3 audit"},
+		{"Heading1", "", "Key takeaways"},
+		{"ListParagraph", "1", "Growth is steady"},
+	}
+	if len(document.Paragraphs) != len(want) {
+		t.Fatalf("got %d paragraphs, want %d", len(document.Paragraphs), len(want))
+	}
+	for i, w := range want {
+		p := document.Paragraphs[i]
+		if p.style() != w.style || p.numID() != w.numID || p.text() != w.text {
+			t.Errorf("paragraph %d = {style %q, numId %q, %q}, want {style %q, numId %q, %q}",
+				i, p.style(), p.numID(), p.text(), w.style, w.numID, w.text)
+		}
+	}
+
+	var numbering struct {
+		Nums []struct {
+			ID            string `xml:"numId,attr"`
+			StartOverride struct {
+				Val string `xml:"val,attr"`
+			} `xml:"lvlOverride>startOverride"`
+		} `xml:"num"`
+	}
+	decodePart(t, parts, "word/numbering.xml", &numbering)
+	if len(numbering.Nums) != 2 || numbering.Nums[1].ID != "2" || numbering.Nums[1].StartOverride.Val != "3" {
+		t.Errorf("numbering = %+v, want the bullet list and a numbered list starting at 3", numbering.Nums)
+	}
+
+	var core struct {
+		Title    string `xml:"title"`
+		Creator  string `xml:"creator"`
+		Language string `xml:"language"`
+		Revision string `xml:"revision"`
+		ID       string `xml:"identifier"`
+		Created  string `xml:"created"`
+	}
+	decodePart(t, parts, "docProps/core.xml", &core)
+	if core.Title != d.Title || core.Creator != d.Author || core.Language != d.Language ||
+		core.Revision != "2" || core.ID != d.ID || core.Created != "2025-03-14T09:30:00Z" {
+		t.Errorf("core properties = %+v", core)
+	}
+}
+
+type docxTestParagraph struct {
+	Properties struct {
+		Style struct {
+			Val string `xml:"val,attr"`
+		} `xml:"pStyle"`
+		Numbering *struct {
+			ID struct {
+				Val string `xml:"val,attr"`
+			} `xml:"numId"`
+		} `xml:"numPr"`
+	} `xml:"pPr"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (p docxTestParagraph) style() string { return p.Properties.Style.Val }
+
+func (p docxTestParagraph) numID() string {
+	if p.Properties.Numbering == nil {
+		return ""
+	}
+	return p.Properties.Numbering.ID.Val
+}
+
+func (p docxTestParagraph) text() string {
+	var b strings.Builder
+	for _, r := range p.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+func unzipParts(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("output is not a ZIP archive: %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		parts[f.Name] = content
+	}
+	return parts
+}
+
+// checkXML reads every token of a document.
+func checkXML(content []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func decodePart(t *testing.T, parts map[string][]byte, name string, v any) {
+	t.Helper()
+	content, ok := parts[name]
+	if !ok {
+		t.Fatalf("part %s is missing", name)
+	}
+	if err := xml.Unmarshal(content, v); err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Format is an output format documents can be exported in.
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	render      func(Document) ([]byte, error)
+}
+
+var formats = []Format{
+	{Name: "txt", Extension: ".txt", ContentType: "text/plain; charset=utf-8", render: TXT},
+	{Name: "md", Extension: ".md", ContentType: "text/markdown; charset=utf-8", render: Markdown},
+	{Name: "pdf", Extension: ".pdf", ContentType: "application/pdf", render: PDF},
+	{Name: "docx", Extension: ".docx", ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", render: DOCX},
+	{Name: "html", Extension: ".html", ContentType: "text/html; charset=utf-8", render: HTML},
+	{Name: "json", Extension: ".json", ContentType: "application/json", render: JSON},
+}
+
+// LookupFormat returns the format called name.
+func LookupFormat(name string) (Format, bool) {
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// FormatNames lists every known format.
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for _, f := range formats {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Render renders d in format f.
+func (f Format) Render(d Document) ([]byte, error) {
+	return f.render(d)
+}
+
+// TXT renders a plain-text file: a header with the title and metadata,
+// then the summary and takeaways.
+func TXT(d Document) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(d.Title + "\n")
+	b.WriteString(strings.Repeat("=", len([]rune(d.Title))) + "\n\n")
+	if fields := d.Fields(); len(fields) > 0 {
+		for _, f := range fields {
+			b.WriteString(f.Label + ": " + f.Value + "\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.TrimRight(d.Text(), "\n") + "\n")
+	return []byte(b.String()), nil
+}
+
+// Markdown renders the summary as Markdown with the metadata as a table.
+func Markdown(d Document) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("# " + d.Title + "\n\n")
+	if fields := d.Fields(); len(fields) > 0 {
+		b.WriteString("| | |\n|---|---|\n")
+		for _, f := range fields {
+			b.WriteString("| " + markdownCell(f.Label) + " | " + markdownCell(f.Value) + " |\n")
+		}
+		b.WriteString("\n")
+	}
+
+	prev := Paragraph
+	for i, block := range d.Blocks() {
+		// lists stay tight, everything else is separated by a blank line
+		if i > 0 && !(isListBlock(block.Kind) && isListBlock(prev)) {
+			b.WriteString("\n")
+		}
+		switch block.Kind {
+		case Heading:
+			b.WriteString("## " + block.Text + "\n")
+		case Bullet:
+			b.WriteString("- " + block.Text + "\n")
+		case Numbered:
+			b.WriteString(block.Marker + " " + block.Text + "\n")
+		default:
+			b.WriteString(block.Text + "\n")
+		}
+		prev = block.Kind
+	}
+	return []byte(b.String()), nil
+}
+
+func isListBlock(k BlockKind) bool {
+	return k == Bullet || k == Numbered
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+// JSON renders the document with its metadata and stats as a JSON object.
+func JSON(d Document) ([]byte, error) {
+	type field struct {
+		Label string `json:"label"`
+		Value string `json:"value"`
+	}
+	out := struct {
+		PdfID      string          `json:"pdf_id,omitempty"`
+		Rev        int             `json:"rev,omitempty"`
+		Title      string          `json:"title"`
+		Language   string          `json:"language,omitempty"`
+		Author     string          `json:"author,omitempty"`
+		Metadata   []field         `json:"metadata"`
+		Stats      *Stats          `json:"stats,omitempty"`
+		Summary    string          `json:"summary"`
+		Takeaways  []string        `json:"takeaways"`
+		Structured json.RawMessage `json:"structured,omitempty"`
+		CreatedAt  string          `json:"created_at,omitempty"`
+	}{
+		PdfID:      d.ID,
+		Rev:        d.Rev,
+		Title:      d.Title,
+		Language:   d.Language,
+		Author:     d.Author,
+		Metadata:   make([]field, 0, len(d.Metadata)),
+		Stats:      d.Stats,
+		Summary:    d.Summary,
+		Takeaways:  d.Takeaways,
+		Structured: d.Structured,
+	}
+	if out.Takeaways == nil {
+		out.Takeaways = []string{}
+	}
+	for _, f := range d.Metadata {
+		out.Metadata = append(out.Metadata, field{Label: f.Label, Value: f.Value})
+	}
+	if !d.CreatedAt.IsZero() {
+		out.CreatedAt = d.CreatedAt.Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html{{with .Language}} lang="{{.}}"{{end}}>
+<head>
+<meta charset="utf-8">
+<meta name="generator" content="{{.Creator}}">
+{{- with .Author}}
+<meta name="author" content="{{.}}">
+{{- end}}
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 46rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.6; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+th { text-align: left; padding-right: 1.5rem; color: #666; font-weight: 600; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- with .Fields}}
+<table>
+{{- range .}}
+<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .Groups}}
+{{- if eq .List "ul"}}
+<ul>
+{{- range .Blocks}}
+<li>{{.Text}}</li>
+{{- end}}
+</ul>
+{{- else if eq .List "ol"}}
+<ol{{if gt .Start 1}} start="{{.Start}}"{{end}}>
+{{- range .Blocks}}
+<li>{{.Text}}</li>
+{{- end}}
+</ol>
+{{- else}}
+{{- range .Blocks}}
+{{- if .IsHeading}}
+<h2>{{.Text}}</h2>
+{{- else}}
+<p>{{.Text}}</p>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+type htmlBlock struct {
+	Text      string
+	IsHeading bool
+}
+
+// htmlGroup is a run of list items ("ul" or "ol") or a single other block.
+// Start is the number of the first item of an "ol".
+type htmlGroup struct {
+	List   string
+	Start  int
+	Blocks []htmlBlock
+}
+
+// HTML renders a standalone HTML page. All text is escaped.
+func HTML(d Document) ([]byte, error) {
+	var groups []htmlGroup
+	for _, b := range d.Blocks() {
+		list := ""
+		switch b.Kind {
+		case Bullet:
+			list = "ul"
+		case Numbered:
+			list = "ol"
+		}
+		if n := len(groups); list != "" && n > 0 && groups[n-1].List == list {
+			groups[n-1].Blocks = append(groups[n-1].Blocks, htmlBlock{Text: b.Text})
+			continue
+		}
+		groups = append(groups, htmlGroup{List: list, Start: b.Number(), Blocks: []htmlBlock{{Text: b.Text, IsHeading: b.Kind == Heading}}})
+	}
+
+	data := struct {
+		Document
+		Creator string
+		Groups  []htmlGroup
+	}{d, Creator, groups}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Creator is recorded as the producing application in exported files.
+const Creator = "SummarizeAI"
+
+const (
+	pdfFont       = "Go"
+	pdfBodySize   = 11
+	pdfLineHeight = 5.5
+	pdfIndent     = 6
+)
+
+// PDF renders d as an A4 document: a title page with the metadata, then the
+// summary with headings, lists and page numbers. The Go fonts are embedded
+// so any UTF-8 text renders without system fonts.
+func PDF(d Document) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", gobold.TTF)
+	pdf.AddUTF8FontFromBytes(pdfFont, "I", goitalic.TTF)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+
+	pdf.SetTitle(d.Title, true)
+	pdf.SetSubject("Summary", true)
+	pdf.SetCreator(Creator, true)
+	pdf.SetProducer(Creator, true)
+	if d.Author != "" {
+		pdf.SetAuthor(d.Author, true)
+	}
+	if d.Language != "" && d.Language != "auto" {
+		pdf.SetLang(d.Language)
+	}
+	if !d.CreatedAt.IsZero() {
+		pdf.SetCreationDate(d.CreatedAt)
+		pdf.SetModificationDate(d.CreatedAt)
+	}
+
+	pdf.AliasNbPages("{nb}")
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-15)
+		pdf.SetFont(pdfFont, "I", 9)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(0, 10, fmt.Sprintf("%s · %d / {nb}", d.Title, pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	writeTitlePage(pdf, d)
+
+	pdf.AddPage()
+	pdf.SetTextColor(0, 0, 0)
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - left - right
+
+	for i, b := range d.Blocks() {
+		switch b.Kind {
+		case Heading:
+			if i > 0 {
+				pdf.Ln(3)
+			}
+			pdf.SetFont(pdfFont, "B", 13)
+			pdf.MultiCell(width, 7, b.Text, "", "L", false)
+			pdf.Ln(1)
+		case Bullet, Numbered:
+			marker := "•"
+			if b.Kind == Numbered {
+				marker = b.Marker
+			}
+			pdf.SetFont(pdfFont, "", pdfBodySize)
+			pdf.SetX(left + pdfIndent)
+			pdf.CellFormat(pdfIndent, pdfLineHeight, marker, "", 0, "L", false, 0, "")
+			pdf.MultiCell(width-2*pdfIndent, pdfLineHeight, b.Text, "", "L", false)
+			pdf.Ln(1)
+		default:
+			pdf.SetFont(pdfFont, "", pdfBodySize)
+			pdf.MultiCell(width, pdfLineHeight, b.Text, "", "J", false)
+			pdf.Ln(3)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("render pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTitlePage(pdf *fpdf.Fpdf, d Document) {
+	pdf.AddPage()
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - left - right
+
+	pdf.SetY(70)
+	pdf.SetFont(pdfFont, "B", 24)
+	pdf.MultiCell(width, 11, d.Title, "", "C", false)
+	pdf.Ln(4)
+	pdf.SetFont(pdfFont, "", 13)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.MultiCell(width, 7, "Summary", "", "C", false)
+
+	fields := d.Fields()
+	if len(fields) == 0 {
+		return
+	}
+	pdf.Ln(20)
+	labelWidth := 45.0
+	tableLeft := left + (width-labelWidth-80)/2
+	for _, f := range fields {
+		pdf.SetX(tableLeft)
+		pdf.SetFont(pdfFont, "B", 10)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(labelWidth, 6, f.Label, "", 0, "L", false, 0, "")
+		pdf.SetFont(pdfFont, "", 10)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.MultiCell(80, 6, f.Value, "", "L", false)
+	}
+}
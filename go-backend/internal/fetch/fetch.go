@@ -0,0 +1,280 @@
+// Package fetch downloads documents from URLs given by users. Only public
+// addresses are dialled unless a private range is explicitly allowed, which
+// keeps the API from being used to reach internal services.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"pdfai/go-backend/internal/document"
+)
+
+var (
+	// ErrInvalidURL is returned for URLs that are not absolute http(s) URLs.
+	ErrInvalidURL = errors.New("invalid_url")
+
+	// ErrBlocked is returned when the URL, or a redirect it leads to,
+	// resolves to an address that may not be fetched.
+	ErrBlocked = errors.New("url_blocked")
+
+	// ErrUnsupportedType is returned for responses that are not one of the
+	// document types that can be uploaded.
+	ErrUnsupportedType = errors.New("unsupported_content_type")
+
+	// ErrTooLarge is returned when the document is bigger than the limit.
+	ErrTooLarge = errors.New("document too large")
+
+	// ErrTimeout is returned when the download did not finish in time.
+	ErrTimeout = errors.New("url_fetch_timeout")
+)
+
+// maxRedirects is how many redirects a download may follow.
+const maxRedirects = 5
+
+// StatusError is returned when the server answers with anything but 200.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server answered %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// blockedPrefixes are ranges that are not private in the sense of
+// netip.Addr.IsPrivate but do not lead to the public internet either.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, may embed a private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, may embed a private IPv4
+	netip.MustParsePrefix("fec0::/10"),      // deprecated site-local
+}
+
+// Fetcher downloads documents. It is safe for concurrent use.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+	allow    []netip.Prefix
+}
+
+// New returns a Fetcher whose downloads take at most timeout and maxBytes.
+// Addresses in allow may be fetched even when they are not public.
+func New(timeout time.Duration, maxBytes int64, allow []netip.Prefix) *Fetcher {
+	f := &Fetcher{maxBytes: maxBytes, allow: allow}
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// checked on the resolved address of every connection, so DNS
+		// answers and redirects cannot lead past it
+		Control: f.checkDial,
+	}
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// a proxy would make the connection, bypassing checkDial
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to a %s URL", ErrBlocked, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+func (f *Fetcher) checkDial(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBlocked, err)
+	}
+	if !f.allowed(ap.Addr()) {
+		return fmt.Errorf("%w: %s is not a public address", ErrBlocked, ap.Addr())
+	}
+	return nil
+}
+
+func (f *Fetcher) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range f.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return public(addr)
+}
+
+// public reports whether addr is a global unicast address outside the
+// private and otherwise internal ranges.
+func public(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Document is a downloaded document, kept in a temporary file until Close.
+type Document struct {
+	File     *os.File
+	Size     int64
+	Type     document.Type
+	Filename string
+}
+
+// Close removes the temporary file.
+func (d *Document) Close() error {
+	err := d.File.Close()
+	if rmErr := os.Remove(d.File.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// Get downloads the document at rawURL. Its type comes from the
+// Content-Type of the response, or from the file name when the server only
+// says application/octet-stream.
+func (f *Fetcher) Get(ctx context.Context, rawURL string) (*Document, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: the URL must be an absolute http(s) URL", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: credentials in the URL are not supported", ErrInvalidURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the URL cannot be requested", ErrInvalidURL)
+	}
+	req.Header.Set("User-Agent", "pdfai-fetch/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, classify(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	// the final URL, after redirects, names the file
+	filename := responseFilename(resp)
+	typ, err := responseType(resp.Header.Get("Content-Type"), filename)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := document.Lookup(filename); !ok || t.Name != typ.Name {
+		filename += typ.Extension()
+	}
+
+	tmp, err := os.CreateTemp("", "fetch_*"+typ.Extension())
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{File: tmp, Type: typ, Filename: filename}
+	doc.Size, err = io.Copy(tmp, io.LimitReader(resp.Body, f.maxBytes+1))
+	if err == nil && doc.Size > f.maxBytes {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		doc.Close()
+		return nil, classify(err)
+	}
+	return doc, nil
+}
+
+// classify marks timeouts with ErrTimeout. It also drops the URL that
+// net/http puts in its errors, since the path and query of a user's URL
+// may carry tokens and the error ends up in logs.
+func classify(err error) error {
+	var ne net.Error
+	timeout := errors.As(err, &ne) && ne.Timeout() && !errors.Is(err, ErrBlocked)
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	if timeout {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	return err
+}
+
+// responseType picks the document type of a response.
+func responseType(contentType, filename string) (document.Type, error) {
+	byName, nameOK := document.Lookup(filename)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" {
+		if nameOK {
+			return byName, nil
+		}
+		return document.Type{}, fmt.Errorf("%w: the server did not say what the document is (use %s)", ErrUnsupportedType, document.Labels())
+	}
+	typ, ok := document.LookupMIME(mediaType)
+	if !ok {
+		return document.Type{}, fmt.Errorf("%w: %s is not supported (use %s)", ErrUnsupportedType, mediaType, document.Labels())
+	}
+	// Markdown is commonly served as text/plain
+	if typ.Name == "txt" && nameOK && byName.Name == "md" {
+		return byName, nil
+	}
+	return typ, nil
+}
+
+// responseFilename is the name the server gives the file in
+// Content-Disposition, or else the last segment of the URL path.
+func responseFilename(resp *http.Response) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" && resp.Request != nil {
+		name = path.Base(resp.Request.URL.Path)
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '/' || r == '\\' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		name = "document"
+	}
+	if len(name) > 200 {
+		name = strings.ToValidUTF8(name[len(name)-200:], "")
+	}
+	return name
+}
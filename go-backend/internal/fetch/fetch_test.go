@@ -0,0 +1,139 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loopback lets the tests reach their httptest servers.
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+
+const pdfBody = "%PDF-1.4\n%%EOF\n"
+
+func serve(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func servePDF(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write([]byte(pdfBody))
+}
+
+func get(t *testing.T, f *Fetcher, rawURL string) (*Document, error) {
+	t.Helper()
+	doc, err := f.Get(context.Background(), rawURL)
+	if doc != nil {
+		t.Cleanup(func() { doc.Close() })
+	}
+	return doc, err
+}
+
+func TestGetLoopback(t *testing.T) {
+	srv := serve(t, servePDF)
+
+	if _, err := get(t, New(5*time.Second, 1<<20, nil), srv.URL+"/report.pdf"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("without allowlist: err = %v, want ErrBlocked", err)
+	}
+
+	doc, err := get(t, New(5*time.Second, 1<<20, loopback), srv.URL+"/report.pdf")
+	if err != nil {
+		t.Fatalf("with allowlist: %v", err)
+	}
+	if doc.Type.Name != "pdf" || doc.Filename != "report.pdf" || doc.Size != int64(len(pdfBody)) {
+		t.Errorf("got type %s, filename %q, size %d", doc.Type.Name, doc.Filename, doc.Size)
+	}
+}
+
+func TestGetRedirectToPrivateAddress(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.0.0.1/internal.pdf", http.StatusFound)
+	})
+
+	if _, err := get(t, New(5*time.Second, 1<<20, loopback), srv.URL+"/report.pdf"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("err = %v, want ErrBlocked", err)
+	}
+}
+
+func TestGetTooLarge(t *testing.T) {
+	body := strings.Repeat("x", 2048)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		if r.URL.Path == "/streamed.pdf" {
+			// flushing before the body is complete leaves out Content-Length
+			w.Write([]byte(body[:10]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(body[10:]))
+			return
+		}
+		w.Header().Set("Content-Length", "2048")
+		w.Write([]byte(body))
+	})
+	f := New(5*time.Second, 1024, loopback)
+
+	for _, path := range []string{"/sized.pdf", "/streamed.pdf"} {
+		if _, err := get(t, f, srv.URL+path); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: err = %v, want ErrTooLarge", path, err)
+		}
+	}
+}
+
+func TestGetContentType(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+		case "/report.pdf", "/download":
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		w.Write([]byte(pdfBody))
+	})
+	f := New(5*time.Second, 1<<20, loopback)
+
+	if _, err := get(t, f, srv.URL+"/image.png"); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("image/png: err = %v, want ErrUnsupportedType", err)
+	}
+
+	doc, err := get(t, f, srv.URL+"/report.pdf")
+	if err != nil {
+		t.Fatalf("octet-stream with a .pdf name: %v", err)
+	}
+	if doc.Type.Name != "pdf" {
+		t.Errorf("octet-stream with a .pdf name: type = %s, want pdf", doc.Type.Name)
+	}
+
+	if _, err := get(t, f, srv.URL+"/download"); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("octet-stream without a known name: err = %v, want ErrUnsupportedType", err)
+	}
+}
+
+func TestGetTimeout(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	if _, err := get(t, New(100*time.Millisecond, 1<<20, loopback), srv.URL+"/report.pdf"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	srv := serve(t, http.NotFound)
+
+	_, err := get(t, New(5*time.Second, 1<<20, loopback), srv.URL+"/report.pdf")
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Fatalf("err = %v, want a StatusError for 404", err)
+	}
+}
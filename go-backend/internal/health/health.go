@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CheckFunc probes one dependency. A nil error means the dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+// Status is the result of the latest probe of one dependency.
+type Status struct {
+	Name          string     `json:"name"`
+	Healthy       bool       `json:"healthy"`
+	LatencyMs     int64      `json:"latency_ms"`
+	CheckedAt     time.Time  `json:"checked_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Checker runs a set of dependency checks and remembers the last error of
+// each one, so the status page can show a dependency that flapped.
+type Checker struct {
+	timeout time.Duration
+	checks  []*check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check. It is not safe to call Add concurrently with Run.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, &check{name: name, fn: fn, status: Status{Name: name}})
+}
+
+// Run probes every dependency concurrently and reports whether all of them
+// are healthy, together with the per-dependency status.
+func (c *Checker) Run(ctx context.Context) (bool, []Status) {
+	statuses := make([]Status, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = ch.run(ctx, c.timeout)
+		}()
+	}
+	wg.Wait()
+
+	healthy := true
+	for _, s := range statuses {
+		healthy = healthy && s.Healthy
+	}
+	return healthy, statuses
+}
+
+func (ch *check) run(ctx context.Context, timeout time.Duration) Status {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := ch.fn(ctx)
+	now := time.Now()
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.status.CheckedAt = now
+	ch.status.LatencyMs = now.Sub(started).Milliseconds()
+	ch.status.Healthy = err == nil
+	if err != nil {
+		ch.status.LastError = err.Error()
+		ch.status.LastErrorAt = &now
+	} else {
+		ch.status.LastSuccessAt = &now
+	}
+	return ch.status
+}
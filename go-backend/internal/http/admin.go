@@ -0,0 +1,83 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"pdfai/go-backend/internal/logging"
+)
+
+// requireAdmin checks the bearer token against the configured admin token.
+// Admin endpoints are disabled entirely when no token is configured.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := h.Config.Admin.Token
+	if token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) AdminConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Config.Redacted()); err != nil {
+		slog.ErrorContext(r.Context(), "encode config response failed", "error", err)
+	}
+}
+
+// AdminLogLevel reads (GET) or changes (PUT {"level": "debug"}) the log
+// level of this process without a restart.
+func (h *Handler) AdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var body struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		lvl, err := logging.ParseLevel(body.Level)
+		if err != nil {
+			http.Error(w, "level must be one of debug, info, warn, error", http.StatusBadRequest)
+			return
+		}
+		previous := logging.Level.Level()
+		logging.Level.Set(lvl)
+		slog.InfoContext(r.Context(), "log level changed", "from", previous.String(), "to", lvl.String())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"level": logging.Level.Level().String(),
+	}); err != nil {
+		slog.ErrorContext(r.Context(), "encode log level response failed", "error", err)
+	}
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dbrepo "pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/export"
+	"pdfai/go-backend/internal/logging"
+	"pdfai/go-backend/internal/summarizer"
+)
+
+// newExportDocument describes one summary revision of a document for export.
+func newExportDocument(file dbrepo.PdfFile, rev dbrepo.SummaryRevision) export.Document {
+	stats := export.ComputeStats(rev.SummaryText)
+	doc := export.Document{
+		ID:              file.ID,
+		Rev:             rev.Rev,
+		Title:           strings.TrimSuffix(file.OriginalName, filepath.Ext(file.OriginalName)),
+		Summary:         rev.SummaryText,
+		Structured:      rev.Structured,
+		Stats:           &stats,
+		Takeaways:       rev.Takeaways,
+		TakeawaysInline: len(rev.Structured) > 0,
+		CreatedAt:       rev.CreatedAt,
+		Metadata: []export.Field{
+			{Label: "Source file", Value: file.OriginalName},
+			{Label: "Source size", Value: formatBytes(file.SizeBytes)},
+			{Label: "Revision", Value: fmt.Sprintf("%d (%s)", rev.Rev, rev.Kind)},
+		},
+	}
+	if doc.Title == "" {
+		doc.Title = "Summary"
+	}
+	if rev.Mode != nil {
+		doc.Metadata = append(doc.Metadata, export.Field{Label: "Mode", Value: *rev.Mode})
+	}
+	if rev.Language != nil && *rev.Language != summarizer.DefaultLanguage {
+		doc.Language = *rev.Language
+		doc.Metadata = append(doc.Metadata, export.Field{Label: "Language", Value: summarizer.LanguageName(*rev.Language) + " (" + *rev.Language + ")"})
+	}
+	if rev.Author != nil {
+		doc.Author = *rev.Author
+		doc.Metadata = append(doc.Metadata, export.Field{Label: "Edited by", Value: *rev.Author})
+	}
+	doc.Metadata = append(doc.Metadata, export.Field{Label: "Summarized", Value: rev.CreatedAt.UTC().Format("2006-01-02 15:04 MST")})
+	return doc
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// exportFilename names an export after the document title, which is the
+// uploaded file name without extension, e.g. "report-summary.pdf".
+func exportFilename(title, ext string) string {
+	return safeFileBase(title) + "-summary" + ext
+}
+
+// safeFileBase replaces the characters that are not allowed in file names
+// on common systems.
+func safeFileBase(name string) string {
+	base := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`"\/:*?<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if strings.Trim(base, "._ ") == "" {
+		base = "document"
+	}
+	return base
+}
+
+// attachment is a Content-Disposition value for a download. Non-ASCII names
+// are sent RFC 2231 encoded.
+func attachment(filename string) string {
+	if v := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return "attachment"
+}
+
+// loadExportDocument loads revision rev of a document, or its current
+// revision when rev is 0, and writes the error response when it cannot.
+func (h *Handler) loadExportDocument(w http.ResponseWriter, r *http.Request, id string, rev int) (export.Document, bool) {
+	ctx := logging.WithPdfID(r.Context(), id)
+	detail, err := h.Repo.GetPdfWithSummary(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get pdf for download failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return export.Document{}, false
+	}
+	if detail == nil {
+		http.NotFound(w, r)
+		return export.Document{}, false
+	}
+	if rev == 0 {
+		if detail.Summary.CurrentRev == nil {
+			http.Error(w, "document has no summary yet", http.StatusConflict)
+			return export.Document{}, false
+		}
+		rev = *detail.Summary.CurrentRev
+	}
+
+	revision, err := h.Repo.GetRevision(ctx, id, rev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision for download failed", "rev", rev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return export.Document{}, false
+	}
+	if revision == nil {
+		http.Error(w, fmt.Sprintf("revision %d does not exist", rev), http.StatusNotFound)
+		return export.Document{}, false
+	}
+	return newExportDocument(detail.File, *revision), true
+}
+
+// exportDocument reads a download request. The body either names a
+// document ({"pdf_id": "..."}), whose current summary and takeaways are
+// used, or carries the text itself ({"summary": "...", "takeaways": [...]}).
+func (h *Handler) exportDocument(w http.ResponseWriter, r *http.Request) (export.Document, bool) {
+	var body struct {
+		PdfID     string   `json:"pdf_id"`
+		Summary   string   `json:"summary"`
+		Takeaways []string `json:"takeaways"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return export.Document{}, false
+	}
+	if body.PdfID == "" {
+		return export.Document{
+			Title:     "Summary",
+			Summary:   body.Summary,
+			Takeaways: body.Takeaways,
+			CreatedAt: time.Now(),
+		}, true
+	}
+	return h.loadExportDocument(w, r, body.PdfID, 0)
+}
+
+// ExportSummary downloads the summary of a document:
+// GET /api/pdfs/{id}/export?format=txt|md|pdf|docx|html|json[&rev=N].
+// The current revision is exported unless rev is given.
+func (h *Handler) ExportSummary(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// expected path: /api/pdfs/{id}/export
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/pdfs/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "export" {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+	ctx := logging.WithPdfID(r.Context(), id)
+
+	name := strings.ToLower(r.URL.Query().Get("format"))
+	if name == "" {
+		name = "pdf"
+	}
+	format, ok := export.LookupFormat(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported format %q (use one of %s)", name, strings.Join(export.FormatNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+	rev := 0
+	if v := r.URL.Query().Get("rev"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "rev must be a revision number", http.StatusBadRequest)
+			return
+		}
+		rev = n
+	}
+
+	doc, ok := h.loadExportDocument(w, r, id, rev)
+	if !ok {
+		return
+	}
+
+	body, err := format.Render(doc)
+	if err != nil {
+		slog.ErrorContext(ctx, "render export failed", "format", format.Name, "error", err)
+		http.Error(w, "failed to export summary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", attachment(exportFilename(doc.Title, format.Extension)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
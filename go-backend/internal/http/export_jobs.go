@@ -0,0 +1,477 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dbrepo "pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/export"
+	"pdfai/go-backend/internal/logging"
+	"pdfai/go-backend/internal/storage"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type exportJobResponse struct {
+	ID               string  `json:"id"`
+	Status           string  `json:"status"`
+	Format           string  `json:"format"`
+	IncludeOriginals bool    `json:"include_originals"`
+	From             *string `json:"from,omitempty"`
+	To               *string `json:"to,omitempty"`
+	DocumentCount    *int    `json:"document_count,omitempty"`
+	SizeBytes        *int64  `json:"size_bytes,omitempty"`
+	Error            *string `json:"error,omitempty"`
+	DownloadURL      string  `json:"download_url,omitempty"`
+	CreatedAt        string  `json:"created_at"`
+	FinishedAt       *string `json:"finished_at,omitempty"`
+}
+
+func newExportJobResponse(j dbrepo.ExportJob) exportJobResponse {
+	resp := exportJobResponse{
+		ID:               j.ID,
+		Status:           j.Status,
+		Format:           j.Format,
+		IncludeOriginals: j.IncludeOriginals,
+		From:             formatOptionalTime(j.CreatedFrom),
+		To:               formatOptionalTime(j.CreatedTo),
+		DocumentCount:    j.DocumentCount,
+		SizeBytes:        j.SizeBytes,
+		Error:            j.ErrorMessage,
+		CreatedAt:        j.CreatedAt.Format(time.RFC3339),
+		FinishedAt:       formatOptionalTime(j.FinishedAt),
+	}
+	if j.Status == dbrepo.ExportSuccess {
+		resp.DownloadURL = "/api/exports/" + j.ID + "/download"
+	}
+	return resp
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// parseExportBound reads the from/to bound of an export. Both RFC 3339
+// times and plain dates are accepted; a plain date as the upper bound
+// includes that whole day.
+func parseExportBound(v string, upper bool) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an RFC 3339 time nor a YYYY-MM-DD date", v)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// Exports starts a bulk export: POST /api/exports with
+// {"format": "pdf", "from": "2024-01-01", "to": "2024-03-31", "include_originals": true}.
+// Every document uploaded in the range is exported with its current summary
+// into a ZIP archive that is built in the background; poll
+// GET /api/exports/{id} until it is ready to download.
+func (h *Handler) Exports(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Location")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Format           string `json:"format"`
+		From             string `json:"from"`
+		To               string `json:"to"`
+		IncludeOriginals bool   `json:"include_originals"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.ToLower(body.Format)
+	if name == "" {
+		name = "pdf"
+	}
+	format, ok := export.LookupFormat(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported format %q (use one of %s)", name, strings.Join(export.FormatNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+	from, err := parseExportBound(body.From, false)
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseExportBound(body.To, true)
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	if !h.ExportJobs.Accepting() {
+		http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := r.Context()
+	job, err := h.Repo.CreateExportJob(ctx, dbrepo.ExportJob{
+		ID:               uuid.New().String(),
+		Format:           format.Name,
+		IncludeOriginals: body.IncludeOriginals,
+		CreatedFrom:      from,
+		CreatedTo:        to,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "create export job failed", "error", err)
+		http.Error(w, "failed to create export", http.StatusInternalServerError)
+		return
+	}
+
+	// like summaries, the archive is built after the request has returned
+	j := *job
+	err = h.ExportJobs.Go(logging.Detach(ctx), j.ID, func(jobCtx context.Context) {
+		h.runExport(jobCtx, j, format)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "start export job failed", "export_id", j.ID, "error", err)
+		if err := h.Repo.FailExportJob(ctx, j.ID, "server shut down before the export started"); err != nil {
+			slog.ErrorContext(ctx, "mark export failed failed", "export_id", j.ID, "error", err)
+		}
+		http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/exports/"+j.ID)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(newExportJobResponse(j)); err != nil {
+		slog.ErrorContext(ctx, "encode export response failed", "error", err)
+	}
+}
+
+// ExportJob serves GET /api/exports/{id}, the state of an export, and
+// GET /api/exports/{id}/download, its archive once it has succeeded.
+func (h *Handler) ExportJob(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// expected path: /api/exports/{id}[/download]
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/exports/"), "/"), "/")
+	if len(parts) > 2 || (len(parts) == 2 && parts[1] != "download") {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := uuid.Parse(parts[0]); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+
+	ctx := r.Context()
+	job, err := h.Repo.GetExportJob(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get export job failed", "export_id", id, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 2 {
+		h.downloadExport(w, r, *job)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(newExportJobResponse(*job)); err != nil {
+		slog.ErrorContext(ctx, "encode export job failed", "error", err)
+	}
+}
+
+func (h *Handler) downloadExport(w http.ResponseWriter, r *http.Request, job dbrepo.ExportJob) {
+	ctx := r.Context()
+	if job.Status != dbrepo.ExportSuccess || job.BlobKey == nil {
+		http.Error(w, fmt.Sprintf("export is %s, not ready for download", job.Status), http.StatusConflict)
+		return
+	}
+
+	f, err := h.ExportStore.Open(*job.BlobKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "export archive is no longer available", http.StatusGone)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "open export archive failed", "export_id", job.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	modified := job.UpdatedAt
+	if job.FinishedAt != nil {
+		modified = *job.FinishedAt
+	}
+	filename := fmt.Sprintf("summaries-export-%s.zip", job.CreatedAt.UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", attachment(filename))
+	http.ServeContent(w, r, filename, modified, f)
+}
+
+// runExport builds the archive of an export job and records the outcome.
+// It is meant to run inside h.Exports; cancelling ctx abandons the archive.
+func (h *Handler) runExport(ctx context.Context, job dbrepo.ExportJob, format export.Format) {
+	ctx, span := tracer.Start(ctx, "export.job", trace.WithAttributes(
+		attribute.String("export.id", job.ID),
+		attribute.String("export.format", job.Format),
+	))
+	defer span.End()
+
+	// status writes must still land after the job itself was cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	if err := h.Repo.StartExportJob(dbCtx, job.ID, h.Config.Server.InstanceID); err != nil {
+		slog.ErrorContext(ctx, "start export job failed", "export_id", job.ID, "error", err)
+		return
+	}
+
+	key := job.ID + ".zip"
+	documents, size, err := h.writeExportArchive(ctx, job, format, key)
+	if ctx.Err() != nil {
+		err = fmt.Errorf("export cancelled: %w", context.Cause(ctx))
+	}
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "export job failed", "export_id", job.ID, "error", err)
+		if err := h.Repo.FailExportJob(dbCtx, job.ID, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "mark export failed failed", "export_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	if err := h.Repo.FinishExportJob(dbCtx, job.ID, key, size, documents); err != nil {
+		slog.ErrorContext(ctx, "save export job failed", "export_id", job.ID, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "export finished", "export_id", job.ID, "documents", documents, "size_bytes", size)
+}
+
+type exportManifest struct {
+	ExportID         string                `json:"export_id"`
+	Format           string                `json:"format"`
+	IncludeOriginals bool                  `json:"include_originals"`
+	From             *string               `json:"from,omitempty"`
+	To               *string               `json:"to,omitempty"`
+	GeneratedAt      string                `json:"generated_at"`
+	Generator        string                `json:"generator"`
+	DocumentCount    int                   `json:"document_count"`
+	Documents        []exportManifestEntry `json:"documents"`
+}
+
+type exportManifestEntry struct {
+	PdfID        string              `json:"pdf_id"`
+	OriginalName string              `json:"original_name"`
+	UploadedAt   string              `json:"uploaded_at"`
+	Status       string              `json:"status"` // exported, no_summary or failed
+	Rev          *int                `json:"rev,omitempty"`
+	Summary      *exportManifestFile `json:"summary,omitempty"`
+	Original     *exportManifestFile `json:"original,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+type exportManifestFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// writeExportArchive streams the ZIP of an export into the blob key and
+// returns the number of documents in it and the archive size. Nothing is
+// left under key when it fails.
+func (h *Handler) writeExportArchive(ctx context.Context, job dbrepo.ExportJob, format export.Format, key string) (documents int, size int64, err error) {
+	items, err := h.Repo.ListExportItems(ctx, job.CreatedFrom, job.CreatedTo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("list documents: %w", err)
+	}
+
+	blob, err := h.ExportStore.Create(key)
+	if err != nil {
+		return 0, 0, fmt.Errorf("create archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			blob.Abort()
+		}
+	}()
+
+	zw := zip.NewWriter(blob)
+	manifest := exportManifest{
+		ExportID:         job.ID,
+		Format:           format.Name,
+		IncludeOriginals: job.IncludeOriginals,
+		From:             formatOptionalTime(job.CreatedFrom),
+		To:               formatOptionalTime(job.CreatedTo),
+		GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+		Generator:        export.Creator,
+		DocumentCount:    len(items),
+		Documents:        make([]exportManifestEntry, 0, len(items)),
+	}
+
+	// names are unique per archive, case-insensitively for Windows and macOS
+	taken := make(map[string]bool)
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return 0, 0, context.Cause(ctx)
+		}
+
+		file := item.File
+		ext := filepath.Ext(file.OriginalName)
+		base := uniqueFileBase(taken, safeFileBase(strings.TrimSuffix(file.OriginalName, ext)))
+		entry := exportManifestEntry{
+			PdfID:        file.ID,
+			OriginalName: file.OriginalName,
+			UploadedAt:   file.CreatedAt.Format(time.RFC3339),
+			Status:       "no_summary",
+		}
+
+		if item.Revision != nil {
+			entry.Rev = &item.Revision.Rev
+			body, rerr := format.Render(newExportDocument(file, *item.Revision))
+			if rerr != nil {
+				slog.WarnContext(logging.WithPdfID(ctx, file.ID), "render export failed", "export_id", job.ID, "format", format.Name, "error", rerr)
+				entry.Status = "failed"
+				entry.Error = rerr.Error()
+			} else {
+				name := "summaries/" + base + "-summary" + format.Extension
+				entry.Summary, err = writeZipEntry(zw, name, item.Revision.CreatedAt, zip.Deflate, bytes.NewReader(body))
+				if err != nil {
+					return 0, 0, fmt.Errorf("write %s: %w", name, err)
+				}
+				entry.Status = "exported"
+			}
+		}
+
+		if job.IncludeOriginals {
+			name := "originals/" + base + strings.ToLower(ext)
+			entry.Original, err = writeZipFile(zw, name, file.CreatedAt, file.StoredPath)
+			if errors.Is(err, os.ErrNotExist) {
+				// keep going; the manifest tells the auditor what is missing
+				slog.WarnContext(logging.WithPdfID(ctx, file.ID), "original missing from storage", "export_id", job.ID, "path", file.StoredPath)
+				entry.Error = "original file is missing from storage"
+				err = nil
+			}
+			if err != nil {
+				return 0, 0, fmt.Errorf("write %s: %w", name, err)
+			}
+		}
+		manifest.Documents = append(manifest.Documents, entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err = writeZipEntry(zw, "manifest.json", time.Now(), zip.Deflate, bytes.NewReader(append(data, '\n'))); err != nil {
+		return 0, 0, fmt.Errorf("write manifest: %w", err)
+	}
+	if err = zw.Close(); err != nil {
+		return 0, 0, fmt.Errorf("finish archive: %w", err)
+	}
+	if err = blob.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("store archive: %w", err)
+	}
+	return len(items), blob.Size(), nil
+}
+
+// uniqueFileBase returns base, or base with a -2, -3, ... suffix when
+// another document in the archive already uses that name.
+func uniqueFileBase(taken map[string]bool, base string) string {
+	name := base
+	for n := 2; taken[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// writeZipFile copies a stored file into the archive. PDFs barely compress
+// and DOCX files are ZIP archives already, so they are stored as is; text,
+// Markdown and HTML originals are deflated.
+func writeZipFile(zw *zip.Writer, name string, modified time.Time, path string) (*exportManifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	method := zip.Deflate
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf", ".docx":
+		method = zip.Store
+	}
+	return writeZipEntry(zw, name, modified, method, f)
+}
+
+func writeZipEntry(zw *zip.Writer, name string, modified time.Time, method uint16, r io.Reader) (*exportManifestFile, error) {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, sum), r)
+	if err != nil {
+		return nil, err
+	}
+	return &exportManifestFile{Path: name, SizeBytes: n, SHA256: hex.EncodeToString(sum.Sum(nil))}, nil
+}
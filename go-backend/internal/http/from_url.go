@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"pdfai/go-backend/internal/fetch"
+	"pdfai/go-backend/internal/metrics"
+	"pdfai/go-backend/internal/summarizer"
+)
+
+// UploadFromURL downloads a document server-side and uploads it like a file:
+// POST /api/pdfs/from-url with
+// {"url": "https://example.com/report.pdf", "mode": "short", "language": "id", "max_words": 200, "password": "..."}.
+// Everything but url is optional, as in the upload form.
+func (h *Handler) UploadFromURL(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.Jobs.Accepting() {
+		http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	// the password is read apart from the options so it never ends up in
+	// the stored options
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64*1024))
+	if err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		URL      string `json:"url"`
+		Password string `json:"password"`
+	}
+	var opts summarizer.Options
+	if err := json.Unmarshal(raw, &body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if body.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.resolveOptions(r.Context(), &opts); err != nil {
+		writeOptionsError(r.Context(), w, err)
+		return
+	}
+
+	doc, err := h.Fetcher.Get(r.Context(), body.URL)
+	if r.Context().Err() != nil {
+		slog.InfoContext(r.Context(), "url fetch aborted by client", "error", r.Context().Err())
+		return
+	}
+	if err != nil {
+		metrics.URLFetches.WithLabelValues(fetchOutcome(err)).Inc()
+		h.writeFetchError(w, r, body.URL, err)
+		return
+	}
+	defer doc.Close()
+	metrics.URLFetches.WithLabelValues("success").Inc()
+	slog.InfoContext(r.Context(), "document fetched", "host", urlHost(body.URL), "filename", doc.Filename, "size_bytes", doc.Size)
+
+	h.acceptUpload(r.Context(), w, doc.Type, doc.File, doc.Filename, doc.Size, opts, body.Password)
+}
+
+// fetchOutcome is the metrics label of a failed download.
+func fetchOutcome(err error) string {
+	var se *fetch.StatusError
+	switch {
+	case errors.Is(err, fetch.ErrInvalidURL):
+		return "invalid_url"
+	case errors.Is(err, fetch.ErrBlocked):
+		return "blocked"
+	case errors.Is(err, fetch.ErrUnsupportedType):
+		return "unsupported_type"
+	case errors.Is(err, fetch.ErrTooLarge):
+		return "too_large"
+	case errors.Is(err, fetch.ErrTimeout):
+		return "timeout"
+	case errors.As(err, &se):
+		return "http_status"
+	}
+	return "error"
+}
+
+func (h *Handler) writeFetchError(w http.ResponseWriter, r *http.Request, rawURL string, err error) {
+	ctx := r.Context()
+	slog.WarnContext(ctx, "url fetch failed", "host", urlHost(rawURL), "error", err)
+
+	var se *fetch.StatusError
+	switch {
+	case errors.Is(err, fetch.ErrInvalidURL):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, fetch.ErrBlocked):
+		http.Error(w, "url_blocked: the URL leads to a private or internal address", http.StatusBadRequest)
+	case errors.Is(err, fetch.ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, fetch.ErrTooLarge):
+		maxMB := h.MaxUploadBytes / (1024 * 1024)
+		http.Error(w, fmt.Sprintf("file too large (max %dMB)", maxMB), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, fetch.ErrTimeout):
+		http.Error(w, "url_fetch_timeout: the document could not be downloaded in time", http.StatusGatewayTimeout)
+	case errors.As(err, &se):
+		http.Error(w, "url_fetch_failed: "+se.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, "url_fetch_failed: the document could not be downloaded", http.StatusBadGateway)
+	}
+}
+
+// urlHost is what gets logged of a user's URL; its path and query may carry
+// tokens.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -1,43 +1,103 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"pdfai/go-backend/internal/config"
 	dbrepo "pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/document"
+	"pdfai/go-backend/internal/export"
+	"pdfai/go-backend/internal/fetch"
+	"pdfai/go-backend/internal/health"
+	"pdfai/go-backend/internal/jobs"
+	"pdfai/go-backend/internal/logging"
+	"pdfai/go-backend/internal/metrics"
+	"pdfai/go-backend/internal/storage"
 	"pdfai/go-backend/internal/summarizer"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("pdfai/go-backend/internal/http")
+
 type Handler struct {
+	Config         *config.Config
 	MaxUploadBytes int64
+	StorageDir     string
 	Repo           *dbrepo.Repository
 	Summarizer     *summarizer.Client
+	Fetcher        *fetch.Fetcher
+	Jobs           *jobs.Runner
+	Quarantine     *storage.Dir // nil unless upload.quarantine_dir is set
+	ExportStore    *storage.Dir
+	ExportJobs     *jobs.Runner
+	Health         *health.Checker
+	StartedAt      time.Time
+
+	// resumable uploads, see uploads.go
+	UploadSessionDir  string
+	UploadSessionTTL  time.Duration
+	MaxResumableBytes int64
+	uploadLocks       sync.Map // session id -> struct{} while a chunk or completion runs
 }
 
-func NewHandler(dbConn *sql.DB) *Handler {
-	maxMBEnv := os.Getenv("MAX_UPLOAD_MB")
-	maxMB, err := strconv.Atoi(maxMBEnv)
-	if err != nil || maxMB <= 0 {
-		maxMB = 10
-	}
-
-	return &Handler{
-		MaxUploadBytes: int64(maxMB) * 1024 * 1024,
+func NewHandler(dbConn *sql.DB, cfg *config.Config) *Handler {
+	h := &Handler{
+		Config:         cfg,
+		MaxUploadBytes: int64(cfg.Upload.MaxMB) * 1024 * 1024,
+		StorageDir:     cfg.Upload.StorageDir,
 		Repo:           dbrepo.NewRepository(dbConn),
-		Summarizer:     summarizer.NewClient(),
-	}
+		Summarizer: summarizer.NewClient(cfg.Summarizer.URL, time.Duration(cfg.Summarizer.Timeout), summarizer.BreakerSettings{
+			FailureThreshold: cfg.Summarizer.Breaker.FailureThreshold,
+			OpenTimeout:      time.Duration(cfg.Summarizer.Breaker.OpenTimeout),
+			HalfOpenRequests: cfg.Summarizer.Breaker.HalfOpenRequests,
+			MaxConcurrent:    cfg.Summarizer.Breaker.MaxConcurrent,
+			QueueTimeout:     time.Duration(cfg.Summarizer.Breaker.QueueTimeout),
+		}),
+		Jobs:        jobs.NewRunner(),
+		ExportStore: storage.NewDir(cfg.Export.StorageDir),
+		ExportJobs:  jobs.NewRunner(),
+		Health:      health.NewChecker(2 * time.Second),
+		StartedAt:   time.Now(),
+
+		// sessions are capped at what upload validation can read; a bigger
+		// one would only fail on completion
+		UploadSessionDir:  cfg.Upload.SessionDir,
+		UploadSessionTTL:  time.Duration(cfg.Upload.SessionTTL),
+		MaxResumableBytes: min(int64(cfg.Upload.ResumableMB())*1024*1024, document.MaxFileBytes),
+	}
+
+	// the allowlist was checked by config.Validate
+	allow, _ := cfg.Fetch.Allowlist()
+	h.Fetcher = fetch.New(time.Duration(cfg.Fetch.Timeout), h.MaxUploadBytes, allow)
+
+	if cfg.Upload.QuarantineDir != "" {
+		h.Quarantine = storage.NewDir(cfg.Upload.QuarantineDir)
+	}
+
+	h.Health.Add("postgres", dbConn.PingContext)
+	h.Health.Add("storage", storageWritable(h.StorageDir))
+	h.Health.Add("export_storage", storageWritable(cfg.Export.StorageDir))
+	h.Health.Add("upload_sessions", storageWritable(cfg.Upload.SessionDir))
+	h.Health.Add("summarizer", h.Summarizer.Ping)
+
+	return h
 }
 
 func (h *Handler) UploadPDF(w http.ResponseWriter, r *http.Request) {
@@ -56,10 +116,15 @@ func (h *Handler) UploadPDF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.Jobs.Accepting() {
+		http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
 
 	if err := r.ParseMultipartForm(h.MaxUploadBytes); err != nil {
-		log.Printf("parse form error: %v", err)
+		slog.WarnContext(r.Context(), "parse upload form failed", "error", err)
 		maxMB := h.MaxUploadBytes / (1024 * 1024)
 		http.Error(w, fmt.Sprintf("file too large (max %dMB) or invalid form", maxMB), http.StatusBadRequest)
 		return
@@ -72,126 +137,395 @@ func (h *Handler) UploadPDF(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	opts, err := summarizer.OptionsFromForm(r.FormValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.resolveOptions(r.Context(), &opts); err != nil {
+		writeOptionsError(r.Context(), w, err)
+		return
+	}
+	// optional password of an encrypted PDF; it is handed to the summary job
+	// and never stored
+	password := r.FormValue("password")
+
 	if header.Size > h.MaxUploadBytes {
 		maxMB := h.MaxUploadBytes / (1024 * 1024)
 		http.Error(w, fmt.Sprintf("file too large (max %dMB)", maxMB), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	ext := filepath.Ext(header.Filename)
-	if ext != ".pdf" {
-		http.Error(w, "only PDF files are allowed", http.StatusBadRequest)
+	typ, ok := document.Lookup(header.Filename)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported file type (use %s)", document.Labels()), http.StatusBadRequest)
 		return
 	}
 
+	h.acceptUpload(r.Context(), w, typ, file, header.Filename, header.Size, opts, password)
+}
+
+// acceptUpload is the upload pipeline shared by file uploads, resumable
+// uploads and documents fetched from a URL: it validates the document,
+// stores it, records it and starts its summary. The caller has checked the
+// size and resolved opts. It returns the id of the new document, or "" when
+// it answered with an error.
+func (h *Handler) acceptUpload(ctx context.Context, w http.ResponseWriter, typ document.Type, file io.ReaderAt, filename string, size int64, opts summarizer.Options, password string) string {
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return ""
+	}
+
+	// nothing is stored for files we refuse
+	if !h.validateUpload(ctx, w, typ, file, filename, size) {
+		return ""
+	}
+
 	id := uuid.New()
-	storageDir := "storage/pdfs"
+	storageDir := h.StorageDir
 	if err := os.MkdirAll(storageDir, 0o755); err != nil {
-		log.Printf("mkdir error: %v", err)
+		slog.ErrorContext(ctx, "create storage dir failed", "dir", storageDir, "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return ""
 	}
 
-	storedPath := filepath.Join(storageDir, fmt.Sprintf("%s.pdf", id.String()))
+	storedPath := filepath.Join(storageDir, id.String()+typ.Extension())
 	out, err := os.Create(storedPath)
 	if err != nil {
-		log.Printf("create file error: %v", err)
+		slog.ErrorContext(ctx, "create stored file failed", "path", storedPath, "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return ""
 	}
 	defer out.Close()
 
-	size, err := io.Copy(out, file)
+	size, err = io.Copy(out, io.NewSectionReader(file, 0, size))
 	if err != nil {
-		log.Printf("copy file error: %v", err)
+		slog.ErrorContext(ctx, "write stored file failed", "path", storedPath, "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return ""
 	}
 
-	log.Printf("uploaded file %s (%d bytes) to %s", header.Filename, size, storedPath)
-
-	ctx := context.Background()
+	ctx = logging.WithPdfID(ctx, id.String())
 	pdfID := id.String()
 
+	slog.InfoContext(ctx, "file uploaded", "filename", filename, "size_bytes", size, "path", storedPath)
+	metrics.UploadBytes.Observe(float64(size))
+
 	fileRecord := dbrepo.PdfFile{
 		ID:           pdfID,
-		OriginalName: header.Filename,
+		OriginalName: filename,
 		StoredPath:   storedPath,
 		SizeBytes:    size,
-		MimeType:     "application/pdf",
+		MimeType:     typ.MimeType,
 	}
 
 	if err := h.Repo.CreatePdfFile(ctx, fileRecord); err != nil {
-		log.Printf("insert pdf_files error: %v", err)
+		slog.ErrorContext(ctx, "insert pdf_files failed", "error", err)
 		http.Error(w, "failed to save metadata", http.StatusInternalServerError)
-		return
+		return ""
 	}
 
 	summaryID := uuid.New().String()
 	summaryRecord := dbrepo.PdfSummary{
-		ID:     summaryID,
-		PdfID:  pdfID,
-		Status: "pending",
+		ID:       summaryID,
+		PdfID:    pdfID,
+		Status:   "pending",
+		Mode:     &opts.Mode,
+		Language: &opts.Language,
+		Options:  optsJSON,
 	}
 
 	if err := h.Repo.CreatePdfSummaryPending(ctx, summaryRecord); err != nil {
-		log.Printf("insert pdf_summaries error: %v", err)
+		slog.ErrorContext(ctx, "insert pdf_summaries failed", "error", err)
 		http.Error(w, "failed to save summary record", http.StatusInternalServerError)
-		return
+		return ""
+	}
+
+	// Call summarizer service asynchronously. The job outlives the request,
+	// so it keeps the request id and trace of the upload, not its cancellation;
+	// it must not touch r once the handler has returned.
+	err = h.Jobs.Go(logging.Detach(ctx), pdfID, func(jobCtx context.Context) {
+		h.runSummary(jobCtx, pdfID, storedPath, opts, password)
+	})
+	if err != nil {
+		// shutdown started after we accepted the upload; leave it for the next start
+		slog.WarnContext(ctx, "start summary job failed", "error", err)
+		if err := h.Repo.MarkSummariesInterrupted(ctx, []string{pdfID}, "server shut down before summarization started"); err != nil {
+			slog.ErrorContext(ctx, "mark summary interrupted failed", "error", err)
+		}
+	}
+
+	type uploadResponse struct {
+		ID           string             `json:"id"`
+		OriginalName string             `json:"original_name"`
+		SizeBytes    int64              `json:"size_bytes"`
+		StoredPath   string             `json:"stored_path"`
+		UploadedAt   string             `json:"uploaded_at"`
+		Options      summarizer.Options `json:"options"`
+	}
+
+	resp := uploadResponse{
+		ID:           id.String(),
+		OriginalName: filename,
+		SizeBytes:    size,
+		StoredPath:   storedPath,
+		UploadedAt:   time.Now().Format(time.RFC3339),
+		Options:      opts,
 	}
 
-	// Call summarizer service asynchronously (for now still blocking this request)
-	go func(pdfID, storedPath string) {
-		absPath, err := filepath.Abs(storedPath)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "encode upload response failed", "error", err)
+	}
+	return pdfID
+}
+
+// summarizerInput is what the summarizer reads for a stored document: PDFs
+// by path, every other type as the text extracted here. The password is
+// only used for encrypted PDFs.
+func summarizerInput(storedPath, password string) (summarizer.Input, error) {
+	typ, ok := document.Lookup(storedPath)
+	if ok && typ.ExtractedHere() {
+		text, err := document.ExtractFile(typ, storedPath)
 		if err != nil {
-			log.Printf("failed to get absolute path: %v", err)
-			_ = h.Repo.UpdateSummaryFailed(context.Background(), pdfID, "failed to resolve file path")
+			return summarizer.Input{}, err
+		}
+		return summarizer.Input{Text: text}, nil
+	}
+	absPath, err := filepath.Abs(storedPath)
+	if err != nil {
+		return summarizer.Input{}, err
+	}
+	return summarizer.Input{FilePath: absPath, Password: password}, nil
+}
+
+// writeDocumentError answers an upload that was refused or whose text
+// cannot be extracted.
+func writeDocumentError(ctx context.Context, w http.ResponseWriter, err error) {
+	var reject *document.RejectError
+	if errors.As(err, &reject) {
+		http.Error(w, reject.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, document.ErrUnreadable) || errors.Is(err, document.ErrNoText) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.ErrorContext(ctx, "extract document text failed", "error", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// runSummary calls the summarizer for a stored document and records the outcome.
+// It is meant to run inside h.Jobs; cancelling ctx aborts the outbound call.
+// The password of an encrypted PDF lives only as long as the job.
+func (h *Handler) runSummary(ctx context.Context, pdfID, storedPath string, opts summarizer.Options, password string) {
+	ctx = logging.WithPdfID(ctx, pdfID)
+	ctx, span := tracer.Start(ctx, "summary.job", trace.WithAttributes(
+		attribute.String("pdf.id", pdfID),
+	))
+	defer span.End()
+
+	// status writes must still land after the job itself was cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	claimed, err := h.Repo.ClaimSummary(dbCtx, pdfID, h.Config.Server.InstanceID)
+	if err != nil {
+		slog.ErrorContext(ctx, "claim summary failed", "error", err)
+		return
+	}
+	if !claimed {
+		slog.InfoContext(ctx, "summary no longer pending, skipping")
+		return
+	}
+
+	in, err := summarizerInput(storedPath, password)
+	if err != nil {
+		slog.ErrorContext(ctx, "read stored document failed", "path", storedPath, "error", err)
+		_ = h.Repo.UpdateSummaryFailed(dbCtx, pdfID, "failed to read stored document")
+		return
+	}
+
+	resp, err := h.Summarizer.Summarize(ctx, in, opts)
+	if ctx.Err() != nil {
+		h.summaryCancelled(dbCtx, pdfID, context.Cause(ctx))
+		return
+	}
+	if errors.Is(err, summarizer.ErrUnavailable) {
+		_ = h.Repo.UpdateSummaryUnavailable(dbCtx, pdfID, err.Error())
+		return
+	}
+	if errors.Is(err, summarizer.ErrPasswordRequired) {
+		_ = h.Repo.UpdateSummaryPasswordRequired(dbCtx, pdfID, err.Error())
+		return
+	}
+	if err != nil {
+		_ = h.Repo.UpdateSummaryFailed(dbCtx, pdfID, err.Error())
+		return
+	}
+
+	rev, err := h.Repo.UpdateSummarySuccess(dbCtx, generatedRevision(pdfID, resp, opts))
+	if err != nil {
+		slog.ErrorContext(ctx, "save summary failed", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "summary saved", "rev", rev)
+}
+
+// summaryLanguage is the language a summary came out in: the one the
+// summarizer reports, or else the one that was asked for.
+func summaryLanguage(resp *summarizer.Response, opts summarizer.Options) string {
+	if resp.Language != "" {
+		return resp.Language
+	}
+	return opts.Language
+}
+
+// generatedRevision turns a summarizer response into the revision to store.
+func generatedRevision(pdfID string, resp *summarizer.Response, opts summarizer.Options) dbrepo.SummaryRevision {
+	language := summaryLanguage(resp, opts)
+	processTime := resp.ProcessTimeMs
+	return dbrepo.SummaryRevision{
+		PdfID:         pdfID,
+		SummaryText:   resp.Summary,
+		Language:      &language,
+		Structured:    resp.Structured,
+		Takeaways:     resp.Takeaways,
+		ProcessTimeMs: &processTime,
+	}
+}
+
+// summaryCancelled handles a job whose context was cancelled. A shutdown
+// leaves the row pending so main can mark it interrupted and the next start
+// resumes it.
+func (h *Handler) summaryCancelled(ctx context.Context, pdfID string, cause error) {
+	if errors.Is(cause, jobs.ErrShuttingDown) {
+		slog.InfoContext(ctx, "summary job stopped by shutdown")
+		return
+	}
+	if errors.Is(cause, jobs.ErrCancelled) {
+		// the cancel endpoint already set the 'cancelled' status
+		slog.InfoContext(ctx, "summary job cancelled by user")
+		return
+	}
+	slog.WarnContext(ctx, "summary job cancelled", "cause", cause)
+	if err := h.Repo.UpdateSummaryFailed(ctx, pdfID, "cancelled: "+cause.Error()); err != nil {
+		slog.ErrorContext(ctx, "save summary failure failed", "error", err)
+	}
+}
+
+// WatchCancellations periodically looks for cancelled summaries among the
+// jobs running on this replica and aborts them. It covers cancel requests
+// that were handled by another replica. It returns when ctx is done.
+func (h *Handler) WatchCancellations(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
 
-		// read optional JSON body: {"mode": "short|detailed|bullet"}
-		var body struct {
-			Mode string `json:"mode"`
+		running := h.Jobs.Running()
+		if len(running) == 0 {
+			continue
+		}
+		cancelled, err := h.Repo.ListCancelledSummaries(ctx, running)
+		if err != nil {
+			slog.WarnContext(ctx, "poll cancelled summaries failed", "error", err)
+			continue
 		}
-		if r.Body != nil {
-			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err.Error() != "EOF" {
-				log.Printf("decode regenerate body error: %v", err)
+		for _, id := range cancelled {
+			if h.Jobs.Cancel(id, jobs.ErrCancelled) {
+				slog.InfoContext(logging.WithPdfID(ctx, id), "aborted summary cancelled on another replica")
 			}
 		}
+	}
+}
 
-		resp, err := h.Summarizer.Summarize(absPath, body.Mode)
-		if err != nil {
-			log.Printf("summarizer error: %v", err)
-			_ = h.Repo.UpdateSummaryFailed(context.Background(), pdfID, err.Error())
+// ResumeInterrupted restarts the summaries that were cut off by a previous
+// shutdown or crash and fails the exports that were. It runs on startup,
+// before this replica takes any work.
+func (h *Handler) ResumeInterrupted(ctx context.Context) error {
+	return h.recoverJobs(ctx, true)
+}
+
+// Heartbeat keeps the summaries and exports of this replica from looking
+// orphaned to the others, and takes over the ones of replicas that stopped
+// sending heartbeats.
+func (h *Handler) Heartbeat(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
 		}
 
-		if err := h.Repo.UpdateSummarySuccess(context.Background(), pdfID, resp.Summary, resp.ProcessTimeMs); err != nil {
-			log.Printf("update summary success error: %v", err)
+		if running := h.Jobs.Running(); len(running) > 0 {
+			if err := h.Repo.TouchSummaries(ctx, running); err != nil {
+				slog.WarnContext(ctx, "summary heartbeat failed", "error", err)
+			}
+		}
+		if running := h.ExportJobs.Running(); len(running) > 0 {
+			if err := h.Repo.TouchExportJobs(ctx, running); err != nil {
+				slog.WarnContext(ctx, "export heartbeat failed", "error", err)
+			}
 		}
-	}(pdfID, storedPath)
 
-	type uploadResponse struct {
-		ID           string `json:"id"`
-		OriginalName string `json:"original_name"`
-		SizeBytes    int64  `json:"size_bytes"`
-		StoredPath   string `json:"stored_path"`
-		UploadedAt   string `json:"uploaded_at"`
+		// a draining replica must not claim work it cannot run
+		if !h.Jobs.Accepting() {
+			continue
+		}
+		if err := h.recoverJobs(ctx, false); err != nil {
+			slog.WarnContext(ctx, "recover orphaned jobs failed", "error", err)
+		}
 	}
+}
 
-	resp := uploadResponse{
-		ID:           id.String(),
-		OriginalName: header.Filename,
-		SizeBytes:    size,
-		StoredPath:   storedPath,
-		UploadedAt:   time.Now().Format(time.RFC3339),
+// recoverJobs resumes orphaned summaries and fails orphaned exports. On
+// startup the jobs this replica's InstanceID owned before the restart count
+// as orphaned too.
+func (h *Handler) recoverJobs(ctx context.Context, startup bool) error {
+	owner := h.Config.Server.InstanceID
+	staleAfter := time.Duration(h.Config.Server.HeartbeatTimeout)
+
+	failed, err := h.Repo.FailOrphanedExportJobs(ctx, owner, staleAfter, startup, "server stopped before the export finished")
+	if err != nil {
+		return err
+	}
+	for _, id := range failed {
+		slog.WarnContext(ctx, "failed orphaned export", "export_id", id)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("encode upload response error: %v", err)
+	claimed, err := h.Repo.ClaimOrphanedSummaries(ctx, owner, staleAfter, startup)
+	if err != nil {
+		return err
 	}
+
+	for _, d := range claimed {
+		f := d.File
+		var opts summarizer.Options
+		if len(d.Summary.Options) > 0 {
+			if err := json.Unmarshal(d.Summary.Options, &opts); err != nil {
+				slog.WarnContext(logging.WithPdfID(ctx, f.ID), "stored summary options unreadable, using defaults", "error", err)
+			}
+		}
+		if err := h.Jobs.Go(context.WithoutCancel(ctx), f.ID, func(jobCtx context.Context) {
+			// the upload's password was never stored; an encrypted PDF ends up
+			// password_required and is summarized again through regenerate
+			h.runSummary(jobCtx, f.ID, f.StoredPath, opts, "")
+		}); err != nil {
+			return err
+		}
+		slog.InfoContext(logging.WithPdfID(ctx, f.ID), "resumed orphaned summary")
+	}
+	return nil
 }
 
 func (h *Handler) ListPDFs(w http.ResponseWriter, r *http.Request) {
@@ -208,7 +542,7 @@ func (h *Handler) ListPDFs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	items, err := h.Repo.ListPdfFiles(ctx)
 	if err != nil {
-		log.Printf("list pdfs error: %v", err)
+		slog.ErrorContext(ctx, "list pdfs failed", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -249,7 +583,7 @@ func (h *Handler) ListPDFs(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("encode list response error: %v", err)
+		slog.ErrorContext(ctx, "encode list response failed", "error", err)
 	}
 }
 
@@ -273,10 +607,10 @@ func (h *Handler) GetPDF(w http.ResponseWriter, r *http.Request) {
 	}
 	id := parts[1]
 
-	ctx := r.Context()
+	ctx := logging.WithPdfID(r.Context(), id)
 	detail, err := h.Repo.GetPdfWithSummary(ctx, id)
 	if err != nil {
-		log.Printf("get pdf error: %v", err)
+		slog.ErrorContext(ctx, "get pdf failed", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -296,10 +630,16 @@ func (h *Handler) GetPDF(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type summaryResp struct {
-		Status       string `json:"status"`
-		SummaryText  string `json:"summary_text"`
-		ProcessMs    int    `json:"process_time_ms"`
-		ErrorMessage string `json:"error_message"`
+		Status       string          `json:"status"`
+		SummaryText  string          `json:"summary_text"`
+		ProcessMs    int             `json:"process_time_ms"`
+		ErrorMessage string          `json:"error_message"`
+		Mode         string          `json:"mode,omitempty"`
+		Language     string          `json:"language,omitempty"`
+		Options      json.RawMessage `json:"options,omitempty"`
+		CurrentRev   *int            `json:"current_rev,omitempty"`
+		Structured   json.RawMessage `json:"structured,omitempty"`
+		Takeaways    []string        `json:"takeaways,omitempty"`
 	}
 
 	type response struct {
@@ -322,6 +662,14 @@ func (h *Handler) GetPDF(w http.ResponseWriter, r *http.Request) {
 	if s.ErrorMessage != nil {
 		errorMsg = *s.ErrorMessage
 	}
+	mode := ""
+	if s.Mode != nil {
+		mode = *s.Mode
+	}
+	language := ""
+	if s.Language != nil {
+		language = *s.Language
+	}
 
 	resp := response{
 		File: fileResp{
@@ -338,12 +686,24 @@ func (h *Handler) GetPDF(w http.ResponseWriter, r *http.Request) {
 			SummaryText:  summaryText,
 			ProcessMs:    process,
 			ErrorMessage: errorMsg,
+			Mode:         mode,
+			Language:     language,
+			Options:      s.Options,
+			CurrentRev:   s.CurrentRev,
+			Structured:   s.Structured,
+			Takeaways:    s.Takeaways,
 		},
 	}
 
+	// edits send this back in If-Match
+	if s.CurrentRev != nil {
+		w.Header().Set("ETag", revisionETag(*s.CurrentRev))
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("encode detail response error: %v", err)
+		slog.ErrorContext(ctx, "encode detail response failed", "error", err)
 	}
 }
 
@@ -371,10 +731,10 @@ func (h *Handler) DeletePDF(w http.ResponseWriter, r *http.Request) {
 	}
 	id := parts[1]
 
-	ctx := r.Context()
+	ctx := logging.WithPdfID(r.Context(), id)
 	storedPath, err := h.Repo.DeletePdf(ctx, id)
 	if err != nil {
-		log.Printf("delete pdf error: %v", err)
+		slog.ErrorContext(ctx, "delete pdf failed", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -385,7 +745,7 @@ func (h *Handler) DeletePDF(w http.ResponseWriter, r *http.Request) {
 
 	// best-effort remove file
 	if err := os.Remove(storedPath); err != nil && !os.IsNotExist(err) {
-		log.Printf("failed to remove file %s: %v", storedPath, err)
+		slog.WarnContext(ctx, "remove stored file failed", "path", storedPath, "error", err)
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -421,9 +781,21 @@ func (h *Handler) PreviewPDF(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	ext := filepath.Ext(header.Filename)
-	if ext != ".pdf" {
-		http.Error(w, "only PDF files are allowed", http.StatusBadRequest)
+	typ, ok := document.Lookup(header.Filename)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported file type (use %s)", document.Labels()), http.StatusBadRequest)
+		return
+	}
+	if typ.ExtractedHere() {
+		text, err := typ.Extract(file, header.Size)
+		if err != nil {
+			writeDocumentError(r.Context(), w, err)
+			return
+		}
+		writePreview(r.Context(), w, previewText(text))
+		return
+	}
+	if !h.validateUpload(r.Context(), w, typ, file, header.Filename, header.Size) {
 		return
 	}
 
@@ -442,18 +814,45 @@ func (h *Handler) PreviewPDF(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call summarizer for preview
-	resp, err := h.Summarizer.GetPreview(tempFile.Name())
+	resp, err := h.Summarizer.GetPreview(r.Context(), tempFile.Name(), r.FormValue("password"))
+	if r.Context().Err() != nil {
+		slog.InfoContext(r.Context(), "preview aborted by client", "error", r.Context().Err())
+		return
+	}
+	if errors.Is(err, summarizer.ErrUnavailable) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "summarizer_unavailable: preview is temporarily unavailable, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, summarizer.ErrPasswordRequired) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
-		log.Printf("preview error: %v", err)
+		slog.ErrorContext(r.Context(), "preview failed", "error", err)
 		http.Error(w, "failed to generate preview", http.StatusInternalServerError)
 		return
 	}
 
+	writePreview(r.Context(), w, resp)
+}
+
+// previewText shortens extracted text the way the summarizer does for PDF
+// previews: whitespace collapsed, at most 1000 characters.
+func previewText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > 1000 {
+		text = string(runes[:1000])
+	}
+	return text
+}
+
+func writePreview(ctx context.Context, w http.ResponseWriter, text string) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]string{
-		"preview_text": resp,
+		"preview_text": text,
 	}); err != nil {
-		log.Printf("encode preview response error: %v", err)
+		slog.ErrorContext(ctx, "encode preview response failed", "error", err)
 	}
 }
 
@@ -468,17 +867,14 @@ func (h *Handler) DownloadSummaryTXT(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		Summary string `json:"summary"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+	doc, ok := h.exportDocument(w, r)
+	if !ok {
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Header().Set("Content-Disposition", "attachment; filename=summary.txt")
-	w.Write([]byte(body.Summary))
+	w.Write([]byte(doc.Text()))
 }
 
 func (h *Handler) DownloadSummaryPDF(w http.ResponseWriter, r *http.Request) {
@@ -492,24 +888,49 @@ func (h *Handler) DownloadSummaryPDF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		Summary string `json:"summary"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+	doc, ok := h.exportDocument(w, r)
+	if !ok {
 		return
 	}
 
-	// Call Python backend for PDF generation
-	resp, err := h.Summarizer.GeneratePDF(body.Summary)
+	pdf, err := export.PDF(doc)
 	if err != nil {
+		slog.ErrorContext(r.Context(), "render summary pdf failed", "error", err)
 		http.Error(w, "failed to generate PDF", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/pdf")
 	w.Header().Set("Content-Disposition", "attachment; filename=summary.pdf")
-	w.Write(resp)
+	w.Write(pdf)
+}
+
+func (h *Handler) DownloadSummaryDOCX(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	doc, ok := h.exportDocument(w, r)
+	if !ok {
+		return
+	}
+
+	docx, err := export.DOCX(doc)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "render summary docx failed", "error", err)
+		http.Error(w, "failed to generate DOCX", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	w.Header().Set("Content-Disposition", "attachment; filename=summary.docx")
+	w.Write(docx)
 }
 
 func (h *Handler) RegenerateSummary(w http.ResponseWriter, r *http.Request) {
@@ -537,10 +958,10 @@ func (h *Handler) RegenerateSummary(w http.ResponseWriter, r *http.Request) {
 	}
 	id := parts[0]
 
-	ctx := r.Context()
+	ctx := logging.WithPdfID(r.Context(), id)
 	detail, err := h.Repo.GetPdfWithSummary(ctx, id)
 	if err != nil {
-		log.Printf("get pdf for regenerate error: %v", err)
+		slog.ErrorContext(ctx, "get pdf for regenerate failed", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -549,46 +970,189 @@ func (h *Handler) RegenerateSummary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	absPath, err := filepath.Abs(detail.File.StoredPath)
+	// optional JSON body: {"mode": "short|detailed|bullet|<template name>", "language": "auto|id|en", "max_words": 200, "password": "..."}
+	// The password of an encrypted PDF is read apart from the options so it
+	// never ends up in the stored options.
+	var opts summarizer.Options
+	var secret struct {
+		Password string `json:"password"`
+	}
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &opts); err != nil {
+				http.Error(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			if err := json.Unmarshal(body, &secret); err != nil {
+				http.Error(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	in, err := summarizerInput(detail.File.StoredPath, secret.Password)
+	if err != nil {
+		slog.ErrorContext(ctx, "read stored document failed", "path", detail.File.StoredPath, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.resolveOptions(ctx, &opts); err != nil {
+		writeOptionsError(ctx, w, err)
+		return
+	}
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// track the call so POST .../summary/cancel and shutdown can abort it
+	jobCtx, done, err := h.Jobs.Track(ctx, id)
 	if err != nil {
-		log.Printf("failed to get absolute path: %v", err)
+		http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	defer done()
+
+	if err := h.Repo.StartSummary(ctx, id, h.Config.Server.InstanceID, opts.Mode, opts.Language, optsJSON); err != nil {
+		slog.ErrorContext(ctx, "mark summary pending failed", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	// optional JSON body: {"mode": "short|detailed|bullet"}
-	var body struct {
-		Mode string `json:"mode"`
+	resp, err := h.Summarizer.Summarize(jobCtx, in, opts)
+	if jobCtx.Err() != nil {
+		cause := context.Cause(jobCtx)
+		dbCtx := context.WithoutCancel(ctx)
+		switch {
+		case errors.Is(cause, jobs.ErrCancelled):
+			http.Error(w, "summary was cancelled", http.StatusConflict)
+		case errors.Is(cause, jobs.ErrShuttingDown):
+			_ = h.Repo.MarkSummariesInterrupted(dbCtx, []string{id}, "server shut down before summarization finished")
+			http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		default:
+			// the client went away; the previous summary text stays as it was
+			slog.InfoContext(ctx, "regenerate aborted by client", "error", cause)
+			_ = h.Repo.UpdateSummaryFailed(dbCtx, id, "regenerate aborted by client")
+		}
+		return
 	}
-	if r.Body != nil {
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err.Error() != "EOF" {
-			log.Printf("decode regenerate body error: %v", err)
+	if errors.Is(err, summarizer.ErrUnavailable) {
+		if err2 := h.Repo.UpdateSummaryUnavailable(ctx, id, err.Error()); err2 != nil {
+			slog.ErrorContext(ctx, "save summary failure failed", "error", err2)
 		}
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "summarizer_unavailable: summarizer is temporarily unavailable, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, summarizer.ErrPasswordRequired) {
+		if err2 := h.Repo.UpdateSummaryPasswordRequired(ctx, id, err.Error()); err2 != nil {
+			slog.ErrorContext(ctx, "save summary failure failed", "error", err2)
+		}
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
 	}
-
-	resp, err := h.Summarizer.Summarize(absPath, body.Mode)
 	if err != nil {
-		log.Printf("summarizer regenerate error: %v", err)
 		if err2 := h.Repo.UpdateSummaryFailed(ctx, id, err.Error()); err2 != nil {
-			log.Printf("update summary failed error: %v", err2)
+			slog.ErrorContext(ctx, "save summary failure failed", "error", err2)
+		}
+		if errors.Is(err, summarizer.ErrInvalidOutput) {
+			http.Error(w, "summarizer returned invalid structured output, try again", http.StatusBadGateway)
+			return
 		}
 		http.Error(w, "failed to generate summary", http.StatusInternalServerError)
 		return
 	}
 
-	if err := h.Repo.UpdateSummarySuccess(ctx, id, resp.Summary, resp.ProcessTimeMs); err != nil {
-		log.Printf("update summary success error: %v", err)
+	rev, err := h.Repo.UpdateSummarySuccess(ctx, generatedRevision(id, resp, opts))
+	if err != nil {
+		slog.ErrorContext(ctx, "save summary failed", "error", err)
 		http.Error(w, "failed to save summary", http.StatusInternalServerError)
 		return
 	}
 
+	if rev > 0 {
+		w.Header().Set("ETag", revisionETag(rev))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
 		"pdf_id":          id,
+		"rev":             rev,
 		"summary_text":    resp.Summary,
+		"structured":      resp.Structured,
+		"takeaways":       resp.Takeaways,
+		"language":        summaryLanguage(resp, opts),
 		"process_time_ms": resp.ProcessTimeMs,
+		"options":         opts,
+	}); err != nil {
+		slog.ErrorContext(ctx, "encode regenerate response failed", "error", err)
+	}
+}
+
+func (h *Handler) CancelSummary(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// expected path: /api/pdfs/{id}/summary/cancel
+	trimmed := strings.TrimPrefix(r.URL.Path, "/api/pdfs/")
+	parts := strings.Split(trimmed, "/")
+	if len(parts) < 3 || parts[0] == "" || parts[1] != "summary" || parts[2] != "cancel" {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+
+	ctx := logging.WithPdfID(r.Context(), id)
+	owner, ok, err := h.Repo.CancelSummary(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "cancel summary failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		detail, err := h.Repo.GetPdfWithSummary(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "get pdf for cancel failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if detail == nil {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, fmt.Sprintf("summary is not in progress (status %q)", detail.Summary.Status), http.StatusConflict)
+		return
+	}
+
+	// abort right away when the job runs here; other replicas pick the
+	// cancellation up from the database
+	abortedHere := h.Jobs.Cancel(id, jobs.ErrCancelled)
+	slog.InfoContext(ctx, "summary cancelled", "owner", owner, "aborted_here", abortedHere)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"pdf_id": id,
+		"status": "cancelled",
 	}); err != nil {
-		log.Printf("encode regenerate response error: %v", err)
+		slog.ErrorContext(ctx, "encode cancel response failed", "error", err)
 	}
 }
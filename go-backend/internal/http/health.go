@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"pdfai/go-backend/internal/health"
+)
+
+// storageWritable checks that files can still be written to dir.
+func storageWritable(dir string) health.CheckFunc {
+	return func(ctx context.Context) error {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(dir, ".readyz-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		_, err = f.Write([]byte("ok"))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if rerr := os.Remove(name); err == nil {
+			err = rerr
+		}
+		return err
+	}
+}
+
+// Healthz is the liveness probe: the process is up and serving HTTP.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
+		slog.ErrorContext(r.Context(), "encode healthz response failed", "error", err)
+	}
+}
+
+// Readyz is the readiness probe: Postgres, storage and the summarizer are
+// all reachable and the server is not shutting down.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	type checkResp struct {
+		Name    string `json:"name"`
+		Healthy bool   `json:"healthy"`
+		Error   string `json:"error,omitempty"`
+	}
+	type response struct {
+		Status string      `json:"status"`
+		Checks []checkResp `json:"checks,omitempty"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !h.Jobs.Accepting() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := json.NewEncoder(w).Encode(response{Status: "shutting_down"}); err != nil {
+			slog.ErrorContext(r.Context(), "encode readyz response failed", "error", err)
+		}
+		return
+	}
+
+	ok, statuses := h.Health.Run(r.Context())
+
+	resp := response{Status: "ready"}
+	for _, s := range statuses {
+		c := checkResp{Name: s.Name, Healthy: s.Healthy}
+		if !s.Healthy {
+			c.Error = s.LastError
+		}
+		resp.Checks = append(resp.Checks, c)
+	}
+	if !ok {
+		resp.Status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(r.Context(), "encode readyz response failed", "error", err)
+	}
+}
+
+// Status is a detailed status page with latency and the last error of
+// every dependency.
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Status       string            `json:"status"`
+		StartedAt    time.Time         `json:"started_at"`
+		UptimeSec    int64             `json:"uptime_seconds"`
+		Accepting    bool              `json:"accepting_jobs"`
+		RunningJobs  int               `json:"running_jobs"`
+		Breakers     map[string]string `json:"summarizer_breakers"`
+		Dependencies []health.Status   `json:"dependencies"`
+	}
+
+	ok, statuses := h.Health.Run(r.Context())
+	status := "ok"
+	if !ok {
+		status = "degraded"
+	}
+
+	resp := response{
+		Status:       status,
+		StartedAt:    h.StartedAt,
+		UptimeSec:    int64(time.Since(h.StartedAt).Seconds()),
+		Accepting:    h.Jobs.Accepting(),
+		RunningJobs:  h.Jobs.Len(),
+		Breakers:     h.Summarizer.BreakerStates(),
+		Dependencies: statuses,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(r.Context(), "encode status response failed", "error", err)
+	}
+}
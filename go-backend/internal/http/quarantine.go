@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pdfai/go-backend/internal/document"
+	"pdfai/go-backend/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// validateUpload checks an uploaded file before anything is stored and
+// writes the error response when it is refused. Suspicious files are copied
+// to the quarantine first, when one is configured.
+func (h *Handler) validateUpload(ctx context.Context, w http.ResponseWriter, typ document.Type, file io.ReaderAt, filename string, size int64) bool {
+	var err error
+	switch {
+	case typ.Validate != nil:
+		err = typ.Validate(file, size)
+	case typ.ExtractedHere():
+		_, err = typ.Extract(file, size)
+	}
+	if err == nil {
+		return true
+	}
+
+	var reject *document.RejectError
+	if errors.As(err, &reject) {
+		metrics.UploadsRejected.WithLabelValues(reject.Code).Inc()
+		slog.WarnContext(ctx, "upload rejected", "filename", filename, "code", reject.Code, "reason", reject.Reason)
+		if reject.Suspicious && h.Quarantine != nil {
+			if id, err := h.quarantine(file, filename, size, reject); err != nil {
+				slog.ErrorContext(ctx, "quarantine upload failed", "error", err)
+			} else {
+				slog.WarnContext(ctx, "upload quarantined", "quarantine_id", id, "code", reject.Code)
+			}
+		}
+	}
+	writeDocumentError(ctx, w, err)
+	return false
+}
+
+// quarantine keeps a rejected upload as <id><ext> next to <id>.json, which
+// records why it was refused.
+func (h *Handler) quarantine(file io.ReaderAt, filename string, size int64, reject *document.RejectError) (string, error) {
+	id := uuid.New().String()
+	ext := ".bin"
+	if typ, ok := document.Lookup(filename); ok {
+		ext = typ.Extension()
+	}
+
+	blob, err := h.Quarantine.Create(id + ext)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(blob, sum), io.NewSectionReader(file, 0, size)); err != nil {
+		blob.Abort()
+		return "", err
+	}
+	if err := blob.Commit(); err != nil {
+		return "", err
+	}
+
+	record, err := json.MarshalIndent(map[string]any{
+		"id":            id,
+		"code":          reject.Code,
+		"reason":        reject.Reason,
+		"original_name": filename,
+		"size_bytes":    size,
+		"sha256":        hex.EncodeToString(sum.Sum(nil)),
+		"received_at":   time.Now().UTC().Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	meta, err := h.Quarantine.Create(id + ".json")
+	if err != nil {
+		return "", err
+	}
+	if _, err := meta.Write(record); err != nil {
+		meta.Abort()
+		return "", err
+	}
+	return id, meta.Commit()
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pdfai/go-backend/internal/diff"
+	"pdfai/go-backend/internal/logging"
+)
+
+const (
+	defaultDiffContext = 3
+	maxDiffContext     = 50
+)
+
+type diffOpResponse struct {
+	Kind diff.Kind `json:"kind"`
+	Text string    `json:"text"`
+}
+
+type diffHunkResponse struct {
+	diff.Hunk
+	Ops []diffOpResponse `json:"ops"`
+}
+
+// diffRevisions compares two summary revisions:
+// GET /api/pdfs/{id}/summaries/diff?from=2&to=5&granularity=word&context=3.
+// to defaults to the current revision and from to the parent of to.
+func (h *Handler) diffRevisions(w http.ResponseWriter, r *http.Request, id string) {
+	ctx := logging.WithPdfID(r.Context(), id)
+	q := r.URL.Query()
+
+	granularity, err := diff.ParseGranularity(q.Get("granularity"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	context := defaultDiffContext
+	if v := q.Get("context"); v != "" {
+		context, err = strconv.Atoi(v)
+		if err != nil || context < 0 || context > maxDiffContext {
+			http.Error(w, fmt.Sprintf("context must be a number between 0 and %d", maxDiffContext), http.StatusBadRequest)
+			return
+		}
+	}
+	revParam := func(name string) (int, bool) {
+		v := q.Get(name)
+		if v == "" {
+			return 0, true
+		}
+		rev, err := strconv.Atoi(v)
+		if err != nil || rev < 1 {
+			http.Error(w, fmt.Sprintf("%s must be a revision number", name), http.StatusBadRequest)
+			return 0, false
+		}
+		return rev, true
+	}
+	fromRev, ok := revParam("from")
+	if !ok {
+		return
+	}
+	toRev, ok := revParam("to")
+	if !ok {
+		return
+	}
+
+	if toRev == 0 {
+		detail, err := h.Repo.GetPdfWithSummary(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "get pdf failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if detail == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if detail.Summary.CurrentRev == nil {
+			http.Error(w, "document has no summary yet", http.StatusConflict)
+			return
+		}
+		toRev = *detail.Summary.CurrentRev
+	}
+
+	to, err := h.Repo.GetRevision(ctx, id, toRev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision failed", "rev", toRev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if to == nil {
+		http.Error(w, fmt.Sprintf("revision %d does not exist", toRev), http.StatusNotFound)
+		return
+	}
+	if fromRev == 0 {
+		if to.ParentRev == nil {
+			http.Error(w, fmt.Sprintf("revision %d has no parent, pass from", toRev), http.StatusBadRequest)
+			return
+		}
+		fromRev = *to.ParentRev
+	}
+
+	from, err := h.Repo.GetRevision(ctx, id, fromRev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision failed", "rev", fromRev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if from == nil {
+		http.Error(w, fmt.Sprintf("revision %d does not exist", fromRev), http.StatusNotFound)
+		return
+	}
+
+	ops := diff.Compute(diff.Split(from.SummaryText, granularity), diff.Split(to.SummaryText, granularity))
+	hunks := diff.Hunks(ops, context)
+
+	type response struct {
+		PdfID       string             `json:"pdf_id"`
+		From        int                `json:"from"`
+		To          int                `json:"to"`
+		Granularity diff.Granularity   `json:"granularity"`
+		Stats       diff.Stats         `json:"stats"`
+		Hunks       []diffHunkResponse `json:"hunks"`
+		Unified     string             `json:"unified"`
+	}
+	resp := response{
+		PdfID:       id,
+		From:        fromRev,
+		To:          toRev,
+		Granularity: granularity,
+		Stats:       diff.Count(ops),
+		Hunks:       make([]diffHunkResponse, 0, len(hunks)),
+		Unified:     diff.Unified(hunks, fmt.Sprintf("rev %d", fromRev), fmt.Sprintf("rev %d", toRev)),
+	}
+	for _, hunk := range hunks {
+		out := diffHunkResponse{Hunk: hunk, Ops: make([]diffOpResponse, 0, len(hunk.Ops))}
+		for _, op := range hunk.Ops {
+			out.Ops = append(out.Ops, diffOpResponse{Kind: op.Kind, Text: strings.Join(op.Tokens, " ")})
+		}
+		resp.Hunks = append(resp.Hunks, out)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "encode diff response failed", "error", err)
+	}
+}
@@ -0,0 +1,415 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dbrepo "pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/logging"
+	"pdfai/go-backend/internal/summarizer"
+)
+
+type revisionResponse struct {
+	Rev           int             `json:"rev"`
+	ParentRev     *int            `json:"parent_rev,omitempty"`
+	Kind          string          `json:"kind"`
+	SummaryText   string          `json:"summary_text"`
+	Mode          *string         `json:"mode,omitempty"`
+	Language      *string         `json:"language,omitempty"`
+	Options       json.RawMessage `json:"options,omitempty"`
+	Structured    json.RawMessage `json:"structured,omitempty"`
+	Takeaways     []string        `json:"takeaways,omitempty"`
+	Author        *string         `json:"author,omitempty"`
+	ProcessTimeMs *int            `json:"process_time_ms,omitempty"`
+	CreatedAt     string          `json:"created_at"`
+}
+
+func newRevisionResponse(rev dbrepo.SummaryRevision) revisionResponse {
+	return revisionResponse{
+		Rev:           rev.Rev,
+		ParentRev:     rev.ParentRev,
+		Kind:          rev.Kind,
+		SummaryText:   rev.SummaryText,
+		Mode:          rev.Mode,
+		Language:      rev.Language,
+		Options:       rev.Options,
+		Structured:    rev.Structured,
+		Takeaways:     rev.Takeaways,
+		Author:        rev.Author,
+		ProcessTimeMs: rev.ProcessTimeMs,
+		CreatedAt:     rev.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// revisionETag is the entity tag of a document's summary at revision rev.
+func revisionETag(rev int) string {
+	return fmt.Sprintf(`"rev-%d"`, rev)
+}
+
+// errPreconditionFailed means If-Match does not name a summary revision.
+var errPreconditionFailed = errors.New("If-Match does not match a summary revision")
+
+// ifMatchRev reads the revision an edit is based on from If-Match. ok is
+// false when the header is absent; "*" matches whatever is current.
+func ifMatchRev(r *http.Request, current int) (rev int, ok bool, err error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return 0, false, nil
+	}
+	if v == "*" {
+		return current, true, nil
+	}
+	// a single tag is expected; weak tags never match for writes
+	tag := strings.TrimSuffix(strings.TrimPrefix(v, `"rev-`), `"`)
+	if strings.HasPrefix(v, "W/") || tag == v {
+		return 0, true, errPreconditionFailed
+	}
+	rev, err = strconv.Atoi(tag)
+	if err != nil || rev < 1 {
+		return 0, true, errPreconditionFailed
+	}
+	return rev, true, nil
+}
+
+// Summaries serves /api/pdfs/{id}/summaries and everything below it.
+func (h *Handler) Summaries(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// expected path: /api/pdfs/{id}/summaries[/diff|/{rev}[/translate]]
+	trimmed := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/pdfs/"), "/")
+	parts := strings.Split(trimmed, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] != "summaries" {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		h.listRevisions(w, r, id)
+	case len(parts) == 2:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+	case len(parts) == 3 && parts[2] == "diff":
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.diffRevisions(w, r, id)
+
+	case len(parts) == 3 || (len(parts) == 4 && parts[3] == "translate"):
+		rev, err := strconv.Atoi(parts[2])
+		if err != nil || rev < 1 {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case len(parts) == 3 && r.Method == http.MethodGet:
+			h.getRevision(w, r, id, rev)
+		case len(parts) == 4 && r.Method == http.MethodPost:
+			h.translateRevision(w, r, id, rev)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) listRevisions(w http.ResponseWriter, r *http.Request, id string) {
+	ctx := logging.WithPdfID(r.Context(), id)
+	detail, err := h.Repo.GetPdfWithSummary(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get pdf failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	revisions, err := h.Repo.ListRevisions(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "list revisions failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	type response struct {
+		PdfID      string             `json:"pdf_id"`
+		CurrentRev *int               `json:"current_rev,omitempty"`
+		Revisions  []revisionResponse `json:"revisions"`
+	}
+	resp := response{
+		PdfID:      id,
+		CurrentRev: detail.Summary.CurrentRev,
+		Revisions:  make([]revisionResponse, 0, len(revisions)),
+	}
+	for _, rev := range revisions {
+		resp.Revisions = append(resp.Revisions, newRevisionResponse(rev))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.ErrorContext(ctx, "encode revisions response failed", "error", err)
+	}
+}
+
+func (h *Handler) getRevision(w http.ResponseWriter, r *http.Request, id string, rev int) {
+	ctx := logging.WithPdfID(r.Context(), id)
+	revision, err := h.Repo.GetRevision(ctx, id, rev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision failed", "rev", rev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if revision == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newRevisionResponse(*revision)); err != nil {
+		slog.ErrorContext(ctx, "encode revision response failed", "error", err)
+	}
+}
+
+// translateRevision translates revision rev into the language of the JSON
+// body ({"language": "fr"}) and stores the result as a new revision whose
+// parent is rev. The current revision does not change.
+func (h *Handler) translateRevision(w http.ResponseWriter, r *http.Request, id string, rev int) {
+	ctx := logging.WithPdfID(r.Context(), id)
+
+	var body struct {
+		Language string `json:"language"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	language := strings.ToLower(strings.TrimSpace(body.Language))
+	if language == "" || language == "auto" || !summarizer.ValidLanguage(language) {
+		http.Error(w, fmt.Sprintf("unsupported language %q (use a language code such as %s)", body.Language, strings.Join(summarizer.Languages[1:], ", ")), http.StatusBadRequest)
+		return
+	}
+
+	source, err := h.Repo.GetRevision(ctx, id, rev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision failed", "rev", rev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if source == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if source.Language != nil && *source.Language == language {
+		http.Error(w, fmt.Sprintf("revision %d is already in %q", rev, language), http.StatusConflict)
+		return
+	}
+
+	started := time.Now()
+	text, err := h.Summarizer.Translate(ctx, source.SummaryText, language)
+	if ctx.Err() != nil {
+		slog.InfoContext(ctx, "translation aborted by client", "error", ctx.Err())
+		return
+	}
+	if errors.Is(err, summarizer.ErrUnavailable) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "summarizer_unavailable: translation is temporarily unavailable, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to translate summary", http.StatusInternalServerError)
+		return
+	}
+	elapsed := int(time.Since(started).Milliseconds())
+
+	translated, err := h.Repo.AddRevision(ctx, dbrepo.SummaryRevision{
+		PdfID:         id,
+		ParentRev:     &rev,
+		Kind:          dbrepo.RevisionTranslated,
+		SummaryText:   text,
+		Mode:          source.Mode,
+		Language:      &language,
+		Options:       source.Options,
+		ProcessTimeMs: &elapsed,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "save translated revision failed", "error", err)
+		http.Error(w, "failed to save translation", http.StatusInternalServerError)
+		return
+	}
+	if translated == nil {
+		// the document was deleted while we were translating
+		http.NotFound(w, r)
+		return
+	}
+	slog.InfoContext(ctx, "summary translated", "from_rev", rev, "rev", translated.Rev, "language", language)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newRevisionResponse(*translated)); err != nil {
+		slog.ErrorContext(ctx, "encode revision response failed", "error", err)
+	}
+}
+
+const maxSummaryLength = 100000
+
+// EditSummary saves a human-edited summary as a new current revision. The
+// edit must name the revision it was based on, through If-Match (the ETag
+// of GET /api/pdfs/{id}) or base_rev in the body; it is rejected when
+// someone else changed the summary in the meantime.
+func (h *Handler) EditSummary(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// expected path: /api/pdfs/{id}/summary
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/pdfs/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] != "summary" {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+	ctx := logging.WithPdfID(r.Context(), id)
+
+	var body struct {
+		SummaryText string    `json:"summary_text"`
+		Takeaways   *[]string `json:"takeaways"`
+		Author      string    `json:"author"`
+		BaseRev     *int      `json:"base_rev"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	text := strings.TrimSpace(body.SummaryText)
+	author := strings.TrimSpace(body.Author)
+	switch {
+	case text == "":
+		http.Error(w, "summary_text is required", http.StatusBadRequest)
+		return
+	case len([]rune(text)) > maxSummaryLength:
+		http.Error(w, fmt.Sprintf("summary_text is longer than %d characters", maxSummaryLength), http.StatusBadRequest)
+		return
+	case author == "":
+		http.Error(w, "author is required", http.StatusBadRequest)
+		return
+	case len([]rune(author)) > 200:
+		http.Error(w, "author is longer than 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := h.Repo.GetPdfWithSummary(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get pdf for edit failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if detail.Summary.CurrentRev == nil {
+		http.Error(w, "document has no summary yet", http.StatusConflict)
+		return
+	}
+
+	baseRev, fromETag, err := ifMatchRev(r, *detail.Summary.CurrentRev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if !fromETag {
+		if body.BaseRev == nil {
+			http.Error(w, "If-Match or base_rev is required", http.StatusPreconditionRequired)
+			return
+		}
+		baseRev = *body.BaseRev
+	}
+	// a mismatch reported over If-Match is a failed precondition
+	conflictStatus := http.StatusConflict
+	if fromETag {
+		conflictStatus = http.StatusPreconditionFailed
+	}
+
+	base, err := h.Repo.GetRevision(ctx, id, baseRev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision failed", "rev", baseRev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if base == nil {
+		http.Error(w, fmt.Sprintf("revision %d does not exist", baseRev), conflictStatus)
+		return
+	}
+
+	takeaways := base.Takeaways
+	if body.Takeaways != nil {
+		if takeaways, err = cleanTakeaways(*body.Takeaways); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the structured JSON no longer matches edited text, so it is not carried over
+	edited, err := h.Repo.ReviseCurrent(ctx, dbrepo.SummaryRevision{
+		PdfID:       id,
+		ParentRev:   &baseRev,
+		Kind:        dbrepo.RevisionEdited,
+		SummaryText: text,
+		Mode:        base.Mode,
+		Language:    base.Language,
+		Options:     base.Options,
+		Takeaways:   takeaways,
+		Author:      &author,
+	}, baseRev)
+	if errors.Is(err, dbrepo.ErrRevisionConflict) {
+		http.Error(w, fmt.Sprintf("summary changed since revision %d, reload and try again", baseRev), conflictStatus)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "save edited summary failed", "error", err)
+		http.Error(w, "failed to save summary", http.StatusInternalServerError)
+		return
+	}
+	if edited == nil {
+		http.NotFound(w, r)
+		return
+	}
+	slog.InfoContext(ctx, "summary edited", "from_rev", baseRev, "rev", edited.Rev, "author", author)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", revisionETag(edited.Rev))
+	if err := json.NewEncoder(w).Encode(newRevisionResponse(*edited)); err != nil {
+		slog.ErrorContext(ctx, "encode revision response failed", "error", err)
+	}
+}
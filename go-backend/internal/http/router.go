@@ -2,11 +2,21 @@ package http
 
 import ("net/http"
 		"strings"
+
+		"pdfai/go-backend/internal/logging"
+		"pdfai/go-backend/internal/metrics"
+
+		"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-func NewRouter(handler *Handler) *http.ServeMux {
+func NewRouter(handler *Handler) http.Handler {
 	mux := http.NewServeMux()
-	
+
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", handler.Healthz)
+	mux.HandleFunc("/readyz", handler.Readyz)
+	mux.HandleFunc("/statusz", handler.Status)
+
 	mux.HandleFunc("/api/pdfs", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
 			handler.UploadPDF(w, r)
@@ -23,6 +33,18 @@ func NewRouter(handler *Handler) *http.ServeMux {
 		handler.PreviewPDF(w, r)
 	})
 
+	mux.HandleFunc("/api/pdfs/from-url", func(w http.ResponseWriter, r *http.Request) {
+		handler.UploadFromURL(w, r)
+	})
+
+	mux.HandleFunc("/api/uploads", func(w http.ResponseWriter, r *http.Request) {
+		handler.Uploads(w, r)
+	})
+
+	mux.HandleFunc("/api/uploads/", func(w http.ResponseWriter, r *http.Request) {
+		handler.UploadSession(w, r)
+	})
+
 	mux.HandleFunc("/api/download/txt", func(w http.ResponseWriter, r *http.Request) {
 		handler.DownloadSummaryTXT(w, r)
 	})
@@ -31,13 +53,70 @@ func NewRouter(handler *Handler) *http.ServeMux {
 		handler.DownloadSummaryPDF(w, r)
 	})
 
+	mux.HandleFunc("/api/download/docx", func(w http.ResponseWriter, r *http.Request) {
+		handler.DownloadSummaryDOCX(w, r)
+	})
+
+	mux.HandleFunc("/api/exports", func(w http.ResponseWriter, r *http.Request) {
+		handler.Exports(w, r)
+	})
+
+	mux.HandleFunc("/api/exports/", func(w http.ResponseWriter, r *http.Request) {
+		handler.ExportJob(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/config", func(w http.ResponseWriter, r *http.Request) {
+		handler.AdminConfig(w, r)
+	})
+
+	mux.HandleFunc("/api/admin/log-level", func(w http.ResponseWriter, r *http.Request) {
+		handler.AdminLogLevel(w, r)
+	})
+
+	mux.HandleFunc("/api/templates", func(w http.ResponseWriter, r *http.Request) {
+		handler.Templates(w, r)
+	})
+
+	mux.HandleFunc("/api/templates/", func(w http.ResponseWriter, r *http.Request) {
+		handler.Template(w, r)
+	})
+
 	mux.HandleFunc("/api/pdfs/", func(w http.ResponseWriter, r *http.Request) {
+		// /api/pdfs/{id}/summaries[/{rev}[/translate]]
+		if parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/pdfs/"), "/"); len(parts) > 1 && parts[1] == "summaries" {
+			handler.Summaries(w, r)
+			return
+		}
+
+		// /api/pdfs/{id}/export
+		if strings.HasSuffix(r.URL.Path, "/export") {
+			handler.ExportSummary(w, r)
+			return
+		}
+
+		// /api/pdfs/{id}/summary/takeaways
+		if strings.HasSuffix(r.URL.Path, "/summary/takeaways") {
+			handler.SummaryTakeaways(w, r)
+			return
+		}
+
+		// /api/pdfs/{id}/summary/cancel
+		if strings.HasSuffix(r.URL.Path, "/summary/cancel") {
+			handler.CancelSummary(w, r)
+			return
+		}
+
 		// /api/pdfs/{id}/summary
 		if strings.HasSuffix(r.URL.Path, "/summary") {
-			if r.Method == http.MethodPost || r.Method == http.MethodOptions {
+			if r.Method == http.MethodPost {
 				handler.RegenerateSummary(w, r)
 				return
 			}
+			if r.Method == http.MethodPut || r.Method == http.MethodOptions {
+				// EditSummary's preflight also covers POST
+				handler.EditSummary(w, r)
+				return
+			}
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
@@ -59,5 +138,68 @@ func NewRouter(handler *Handler) *http.ServeMux {
 		}
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	})
-	return mux
+	traced := otelhttp.NewHandler(mux, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + routeLabel(mux, r)
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/metrics", "/healthz", "/readyz":
+				return false
+			}
+			return true
+		}),
+	)
+	return metrics.Middleware(logging.Middleware(traced), func(r *http.Request) string {
+		return routeLabel(mux, r)
+	})
+}
+
+// routeLabel turns a request path into a metrics label without ids so the
+// number of label values stays bounded.
+func routeLabel(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		return "unmatched"
+	}
+	if pattern == "/api/templates/" {
+		return "/api/templates/{id}"
+	}
+	if pattern == "/api/exports/" {
+		if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/download") {
+			return "/api/exports/{id}/download"
+		}
+		return "/api/exports/{id}"
+	}
+	if pattern != "/api/pdfs/" {
+		return pattern
+	}
+
+	// /api/pdfs/{id}[/summary...]
+	rest := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/pdfs/"), "/"), "/")
+	if len(rest) == 1 {
+		return "/api/pdfs/{id}"
+	}
+	sub := rest[1:]
+	if len(sub) > 1 && sub[0] == "summaries" && sub[1] != "diff" {
+		sub[1] = "{rev}"
+	}
+	if suffix := strings.Join(sub, "/"); pdfSubroutes[suffix] {
+		return "/api/pdfs/{id}/" + suffix
+	}
+	return "/api/pdfs/{id}/other"
+}
+
+// pdfSubroutes are the paths below /api/pdfs/{id}/ that get their own
+// label. Anything else is counted as "other" so clients cannot add label
+// values at will.
+var pdfSubroutes = map[string]bool{
+	"summary":                   true,
+	"summary/cancel":            true,
+	"summary/takeaways":         true,
+	"summaries":                 true,
+	"summaries/{rev}":           true,
+	"summaries/{rev}/translate": true,
+	"summaries/diff":            true,
+	"export":                    true,
 }
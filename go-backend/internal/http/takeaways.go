@@ -0,0 +1,248 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	dbrepo "pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/logging"
+	"pdfai/go-backend/internal/summarizer"
+)
+
+const (
+	maxTakeaways      = 20
+	maxTakeawayLength = 500
+)
+
+// withTakeaways derives a new revision from base with other takeaways. For
+// a structured summary the JSON and its text rendering are updated as well.
+func withTakeaways(base dbrepo.SummaryRevision, takeaways []string, kind string) (dbrepo.SummaryRevision, error) {
+	parent := base.Rev
+	rev := dbrepo.SummaryRevision{
+		PdfID:       base.PdfID,
+		ParentRev:   &parent,
+		Kind:        kind,
+		SummaryText: base.SummaryText,
+		Mode:        base.Mode,
+		Language:    base.Language,
+		Options:     base.Options,
+		Structured:  base.Structured,
+		Takeaways:   takeaways,
+	}
+
+	if len(base.Structured) > 0 {
+		var s summarizer.Structured
+		if err := json.Unmarshal(base.Structured, &s); err != nil {
+			return rev, fmt.Errorf("decode structured summary: %w", err)
+		}
+		s.Takeaways = takeaways
+		structured, err := json.Marshal(s)
+		if err != nil {
+			return rev, err
+		}
+		rev.Structured = structured
+		rev.SummaryText = s.Text()
+	}
+	return rev, nil
+}
+
+// cleanTakeaways trims edited takeaways and enforces the size limits.
+func cleanTakeaways(in []string) ([]string, error) {
+	if len(in) > maxTakeaways {
+		return nil, fmt.Errorf("at most %d takeaways are allowed", maxTakeaways)
+	}
+	out := make([]string, 0, len(in))
+	for i, t := range in {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return nil, fmt.Errorf("takeaways[%d] must not be empty", i)
+		}
+		if len([]rune(t)) > maxTakeawayLength {
+			return nil, fmt.Errorf("takeaways[%d] is longer than %d characters", i, maxTakeawayLength)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// SummaryTakeaways regenerates (POST) or replaces (PUT) the takeaways of
+// the current summary. Either way a new revision derived from the current
+// one is stored and becomes current.
+func (h *Handler) SummaryTakeaways(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// expected path: /api/pdfs/{id}/summary/takeaways
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/pdfs/"), "/")
+	if len(parts) < 3 || parts[0] == "" || parts[1] != "summary" || parts[2] != "takeaways" {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+	ctx := logging.WithPdfID(r.Context(), id)
+
+	// PUT body: {"takeaways": ["..."], "author": "...", "base_rev": 3}; an
+	// edit must name its base revision through If-Match or base_rev so a
+	// stale client cannot overwrite a newer revision. POST regenerates from
+	// the current revision unless one is named.
+	var body struct {
+		Takeaways []string `json:"takeaways"`
+		Author    string   `json:"author"`
+		BaseRev   *int     `json:"base_rev"`
+	}
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if body.Takeaways == nil {
+			http.Error(w, "takeaways is required", http.StatusBadRequest)
+			return
+		}
+		cleaned, err := cleanTakeaways(body.Takeaways)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body.Takeaways = cleaned
+	}
+	// optional POST body: {"password": "..."} for an encrypted PDF
+	var secret struct {
+		Password string `json:"password"`
+	}
+	if r.Method == http.MethodPost && r.Body != nil {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&secret); err != nil && err != io.EOF {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	detail, err := h.Repo.GetPdfWithSummary(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get pdf failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if detail.Summary.CurrentRev == nil {
+		http.Error(w, "document has no summary yet", http.StatusConflict)
+		return
+	}
+	baseRev, fromETag, err := ifMatchRev(r, *detail.Summary.CurrentRev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if !fromETag {
+		if r.Method == http.MethodPut && body.BaseRev == nil {
+			http.Error(w, "If-Match or base_rev is required", http.StatusPreconditionRequired)
+			return
+		}
+		baseRev = *detail.Summary.CurrentRev
+		if body.BaseRev != nil {
+			baseRev = *body.BaseRev
+		}
+	}
+	conflictStatus := http.StatusConflict
+	if fromETag {
+		conflictStatus = http.StatusPreconditionFailed
+	}
+
+	base, err := h.Repo.GetRevision(ctx, id, baseRev)
+	if err != nil {
+		slog.ErrorContext(ctx, "get revision failed", "rev", baseRev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if base == nil {
+		http.Error(w, fmt.Sprintf("revision %d does not exist", baseRev), conflictStatus)
+		return
+	}
+
+	takeaways := body.Takeaways
+	kind := dbrepo.RevisionEdited
+	if r.Method == http.MethodPost {
+		kind = dbrepo.RevisionGenerated
+		language := summarizer.DefaultLanguage
+		if base.Language != nil {
+			language = *base.Language
+		}
+		in, err := summarizerInput(detail.File.StoredPath, secret.Password)
+		if err != nil {
+			slog.ErrorContext(ctx, "read stored document failed", "path", detail.File.StoredPath, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		takeaways, err = h.Summarizer.Takeaways(ctx, in, language)
+		if ctx.Err() != nil {
+			slog.InfoContext(ctx, "takeaways aborted by client", "error", ctx.Err())
+			return
+		}
+		if errors.Is(err, summarizer.ErrUnavailable) {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "summarizer_unavailable: takeaways are temporarily unavailable, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		if errors.Is(err, summarizer.ErrPasswordRequired) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to generate takeaways", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rev, err := withTakeaways(*base, takeaways, kind)
+	if err != nil {
+		slog.ErrorContext(ctx, "derive revision failed", "rev", baseRev, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if author := strings.TrimSpace(body.Author); kind == dbrepo.RevisionEdited && author != "" {
+		rev.Author = &author
+	}
+
+	created, err := h.Repo.ReviseCurrent(ctx, rev, baseRev)
+	if errors.Is(err, dbrepo.ErrRevisionConflict) {
+		http.Error(w, fmt.Sprintf("summary changed since revision %d, reload and try again", baseRev), conflictStatus)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "save takeaways failed", "error", err)
+		http.Error(w, "failed to save takeaways", http.StatusInternalServerError)
+		return
+	}
+	if created == nil {
+		http.NotFound(w, r)
+		return
+	}
+	slog.InfoContext(ctx, "takeaways updated", "kind", kind, "from_rev", baseRev, "rev", created.Rev)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", revisionETag(created.Rev))
+	if err := json.NewEncoder(w).Encode(newRevisionResponse(*created)); err != nil {
+		slog.ErrorContext(ctx, "encode revision response failed", "error", err)
+	}
+}
@@ -0,0 +1,292 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/summarizer"
+)
+
+// errInvalidOptions wraps every resolveOptions error caused by the request
+// rather than by the database.
+var errInvalidOptions = errors.New("invalid summary options")
+
+// resolveOptions normalizes opts and, for a custom mode, renders the
+// template's prompt into opts.Prompt. Template defaults fill in language
+// and max_words when the request left them empty.
+func (h *Handler) resolveOptions(ctx context.Context, opts *summarizer.Options) error {
+	// a prompt is only ever taken from a stored template
+	opts.Prompt = ""
+
+	if strings.TrimSpace(opts.Mode) == "" || summarizer.IsBuiltinMode(opts.Mode) {
+		if err := opts.Normalize(); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidOptions, err)
+		}
+		return nil
+	}
+
+	tmpl, err := h.Repo.GetTemplateByName(ctx, strings.ToLower(strings.TrimSpace(opts.Mode)))
+	if err != nil {
+		return err
+	}
+	if tmpl == nil {
+		return fmt.Errorf("%w: unknown mode %q (use one of %s or a template name)", errInvalidOptions, opts.Mode, strings.Join(summarizer.Modes, ", "))
+	}
+
+	if strings.TrimSpace(opts.Language) == "" && tmpl.DefaultLanguage != nil {
+		opts.Language = *tmpl.DefaultLanguage
+	}
+	if opts.MaxWords == 0 && tmpl.DefaultMaxWords != nil {
+		opts.MaxWords = *tmpl.DefaultMaxWords
+	}
+	if err := opts.Normalize(); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidOptions, err)
+	}
+
+	prompt, err := summarizer.RenderPrompt(tmpl.Prompt, *opts)
+	if err != nil {
+		return fmt.Errorf("%w: template %q: %v", errInvalidOptions, tmpl.Name, err)
+	}
+	opts.Prompt = prompt
+	return nil
+}
+
+// writeOptionsError answers a failed resolveOptions call.
+func writeOptionsError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidOptions) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.ErrorContext(ctx, "resolve summary options failed", "error", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+type templateRequest struct {
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	Prompt          string  `json:"prompt"`
+	DefaultLanguage *string `json:"default_language"`
+	DefaultMaxWords *int    `json:"default_max_words"`
+}
+
+// validate checks the request and returns the template it describes.
+func (req templateRequest) validate() (db.SummaryTemplate, error) {
+	t := db.SummaryTemplate{
+		Name:        strings.ToLower(strings.TrimSpace(req.Name)),
+		Description: strings.TrimSpace(req.Description),
+		Prompt:      strings.TrimSpace(req.Prompt),
+	}
+	if err := summarizer.ValidateTemplateName(t.Name); err != nil {
+		return t, err
+	}
+	if t.Prompt == "" {
+		return t, errors.New("prompt is required")
+	}
+
+	// the defaults must be valid options and the prompt must render with them
+	sample := summarizer.Options{Mode: t.Name}
+	if req.DefaultLanguage != nil {
+		sample.Language = *req.DefaultLanguage
+	}
+	if req.DefaultMaxWords != nil {
+		sample.MaxWords = *req.DefaultMaxWords
+	}
+	if err := sample.Normalize(); err != nil {
+		return t, err
+	}
+	if _, err := summarizer.RenderPrompt(t.Prompt, sample); err != nil {
+		return t, err
+	}
+
+	if req.DefaultLanguage != nil && strings.TrimSpace(*req.DefaultLanguage) != "" {
+		t.DefaultLanguage = &sample.Language
+	}
+	if req.DefaultMaxWords != nil && *req.DefaultMaxWords > 0 {
+		t.DefaultMaxWords = &sample.MaxWords
+	}
+	return t, nil
+}
+
+type templateResponse struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Prompt          string    `json:"prompt"`
+	DefaultLanguage *string   `json:"default_language,omitempty"`
+	DefaultMaxWords *int      `json:"default_max_words,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func newTemplateResponse(t db.SummaryTemplate) templateResponse {
+	return templateResponse{
+		ID:              t.ID,
+		Name:            t.Name,
+		Description:     t.Description,
+		Prompt:          t.Prompt,
+		DefaultLanguage: t.DefaultLanguage,
+		DefaultMaxWords: t.DefaultMaxWords,
+		CreatedAt:       t.CreatedAt,
+		UpdatedAt:       t.UpdatedAt,
+	}
+}
+
+// Templates lists (GET) or creates (POST) summary templates.
+func (h *Handler) Templates(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodGet:
+		templates, err := h.Repo.ListTemplates(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "list templates failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		resp := make([]templateResponse, 0, len(templates))
+		for _, t := range templates {
+			resp = append(resp, newTemplateResponse(t))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.ErrorContext(ctx, "encode templates response failed", "error", err)
+		}
+
+	case http.MethodPost:
+		t, ok := decodeTemplate(w, r)
+		if !ok {
+			return
+		}
+		t.ID = uuid.NewString()
+		created, err := h.Repo.CreateTemplate(ctx, t)
+		if err != nil {
+			if db.IsUniqueViolation(err) {
+				http.Error(w, fmt.Sprintf("template %q already exists", t.Name), http.StatusConflict)
+				return
+			}
+			slog.ErrorContext(ctx, "create template failed", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		slog.InfoContext(ctx, "template created", "template_id", created.ID, "name", created.Name)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(newTemplateResponse(*created)); err != nil {
+			slog.ErrorContext(ctx, "encode template response failed", "error", err)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// Template reads (GET), replaces (PUT) or deletes (DELETE) one summary
+// template.
+func (h *Handler) Template(w http.ResponseWriter, r *http.Request) {
+	// CORS
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// expected path: /api/templates/{id}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/templates/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	var (
+		t   *db.SummaryTemplate
+		err error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		t, err = h.Repo.GetTemplate(ctx, id)
+
+	case http.MethodPut:
+		req, ok := decodeTemplate(w, r)
+		if !ok {
+			return
+		}
+		req.ID = id
+		t, err = h.Repo.UpdateTemplate(ctx, req)
+		if err != nil && db.IsUniqueViolation(err) {
+			http.Error(w, fmt.Sprintf("template %q already exists", req.Name), http.StatusConflict)
+			return
+		}
+
+	case http.MethodDelete:
+		deleted, err := h.Repo.DeleteTemplate(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "delete template failed", "template_id", id, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.NotFound(w, r)
+			return
+		}
+		slog.InfoContext(ctx, "template deleted", "template_id", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "template request failed", "template_id", id, "method", r.Method, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newTemplateResponse(*t)); err != nil {
+		slog.ErrorContext(ctx, "encode template response failed", "error", err)
+	}
+}
+
+func decodeTemplate(w http.ResponseWriter, r *http.Request) (db.SummaryTemplate, bool) {
+	var req templateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return db.SummaryTemplate{}, false
+	}
+	t, err := req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return db.SummaryTemplate{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,539 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dbrepo "pdfai/go-backend/internal/db"
+	"pdfai/go-backend/internal/document"
+	"pdfai/go-backend/internal/summarizer"
+
+	"github.com/google/uuid"
+)
+
+// Resumable uploads send a large file in chunks over several requests, so a
+// dropped connection only costs the chunk in flight:
+//
+//	POST   /api/uploads                 {"filename", "size", "sha256", "mode", "language", "max_words"}
+//	HEAD   /api/uploads/{id}            Upload-Offset: bytes received so far
+//	PATCH  /api/uploads/{id}            Upload-Offset + chunk, optional Upload-Checksum: sha256 <base64>
+//	POST   /api/uploads/{id}/complete   {"password"}; creates the document
+//	DELETE /api/uploads/{id}
+//
+// The received bytes are appended to <id>.part in the session dir until the
+// session is completed. Each chunk is written under a claim in the database
+// (status 'writing'), so replicas sharing the session dir cannot interleave.
+
+type uploadSessionResponse struct {
+	ID        string  `json:"id"`
+	Status    string  `json:"status"`
+	Filename  string  `json:"filename"`
+	SizeBytes int64   `json:"size_bytes"`
+	Offset    int64   `json:"offset"`
+	PdfID     *string `json:"pdf_id,omitempty"`
+	ExpiresAt string  `json:"expires_at"`
+	UploadURL string  `json:"upload_url"`
+}
+
+func newUploadSessionResponse(s dbrepo.UploadSession) uploadSessionResponse {
+	return uploadSessionResponse{
+		ID:        s.ID,
+		Status:    s.Status,
+		Filename:  s.Filename,
+		SizeBytes: s.SizeBytes,
+		Offset:    s.Offset,
+		PdfID:     s.PdfID,
+		ExpiresAt: s.ExpiresAt.Format(time.RFC3339),
+		UploadURL: "/api/uploads/" + s.ID,
+	}
+}
+
+func setUploadCORS(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, HEAD, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Upload-Offset, Upload-Checksum")
+	w.Header().Set("Access-Control-Expose-Headers", "Location, Upload-Offset, Upload-Length")
+}
+
+func (h *Handler) uploadPartPath(id string) string {
+	return filepath.Join(h.UploadSessionDir, id+".part")
+}
+
+// Uploads starts a resumable upload: POST /api/uploads. The file type is
+// taken from filename and the summary options are fixed now, as in the
+// upload form; sha256 is the hex digest of the whole file and is optional.
+func (h *Handler) Uploads(w http.ResponseWriter, r *http.Request) {
+	setUploadCORS(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64*1024))
+	if err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Filename string `json:"filename"`
+		Size     int64  `json:"size"`
+		SHA256   string `json:"sha256"`
+	}
+	var opts summarizer.Options
+	if err := json.Unmarshal(raw, &body); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	filename := path.Base(strings.ReplaceAll(strings.TrimSpace(body.Filename), `\`, "/"))
+	if filename == "" || filename == "." || filename == "/" {
+		http.Error(w, "filename is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := document.Lookup(filename); !ok {
+		http.Error(w, fmt.Sprintf("unsupported file type (use %s)", document.Labels()), http.StatusBadRequest)
+		return
+	}
+	if body.Size <= 0 {
+		http.Error(w, "size must be positive", http.StatusBadRequest)
+		return
+	}
+	if body.Size > h.MaxResumableBytes {
+		maxMB := h.MaxResumableBytes / (1024 * 1024)
+		http.Error(w, fmt.Sprintf("file too large (max %dMB)", maxMB), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var checksum *string
+	if body.SHA256 != "" {
+		sum := strings.ToLower(body.SHA256)
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			http.Error(w, "sha256 must be the hex SHA-256 of the file", http.StatusBadRequest)
+			return
+		}
+		checksum = &sum
+	}
+	if err := h.resolveOptions(r.Context(), &opts); err != nil {
+		writeOptionsError(r.Context(), w, err)
+		return
+	}
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	id := uuid.New().String()
+	if err := os.MkdirAll(h.UploadSessionDir, 0o755); err != nil {
+		slog.ErrorContext(r.Context(), "create upload session dir failed", "dir", h.UploadSessionDir, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	part, err := os.Create(h.uploadPartPath(id))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create upload part failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	part.Close()
+
+	session, err := h.Repo.CreateUploadSession(r.Context(), dbrepo.UploadSession{
+		ID:        id,
+		Filename:  filename,
+		SizeBytes: body.Size,
+		Checksum:  checksum,
+		Options:   optsJSON,
+		ExpiresAt: time.Now().Add(h.UploadSessionTTL),
+	})
+	if err != nil {
+		os.Remove(h.uploadPartPath(id))
+		slog.ErrorContext(r.Context(), "insert upload session failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "upload session started", "upload_id", id, "filename", filename, "size_bytes", body.Size)
+
+	w.Header().Set("Location", "/api/uploads/"+id)
+	w.Header().Set("Upload-Offset", "0")
+	w.Header().Set("Upload-Length", strconv.FormatInt(body.Size, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newUploadSessionResponse(*session)); err != nil {
+		slog.ErrorContext(r.Context(), "encode upload session failed", "error", err)
+	}
+}
+
+// UploadSession serves /api/uploads/{id} and /api/uploads/{id}/complete.
+func (h *Handler) UploadSession(w http.ResponseWriter, r *http.Request) {
+	setUploadCORS(w)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// expected path: /api/uploads/{id} or /api/uploads/{id}/complete
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/uploads/"), "/")
+	if _, err := uuid.Parse(parts[0]); err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "complete") {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+	complete := len(parts) == 2
+
+	switch {
+	case complete && r.Method == http.MethodPost:
+	case !complete && (r.Method == http.MethodHead || r.Method == http.MethodGet || r.Method == http.MethodPatch || r.Method == http.MethodDelete):
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// chunks and completion of one session are handled one at a time here;
+	// across replicas the claims in the database keep them apart
+	if r.Method != http.MethodHead && r.Method != http.MethodGet {
+		if _, busy := h.uploadLocks.LoadOrStore(id, struct{}{}); busy {
+			http.Error(w, "another request for this upload is in progress", http.StatusConflict)
+			return
+		}
+		defer h.uploadLocks.Delete(id)
+	}
+
+	ctx := r.Context()
+	session, err := h.Repo.GetUploadSession(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "get upload session failed", "upload_id", id, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.SizeBytes, 10))
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newUploadSessionResponse(*session)); err != nil {
+			slog.ErrorContext(ctx, "encode upload session failed", "error", err)
+		}
+		return
+	case http.MethodDelete:
+		h.deleteUpload(ctx, w, session)
+		return
+	}
+
+	if session.Status == dbrepo.UploadCompleted {
+		http.Error(w, "upload is already completed", http.StatusConflict)
+		return
+	}
+	if time.Now().After(session.ExpiresAt) {
+		http.Error(w, "upload session expired, start a new upload", http.StatusGone)
+		return
+	}
+	if complete {
+		h.completeUpload(ctx, w, r, session)
+		return
+	}
+	h.patchUpload(ctx, w, r, session)
+}
+
+const (
+	// uploadWriteTimeout bounds how long one chunk may take to arrive.
+	uploadWriteTimeout = 15 * time.Minute
+	// uploadWriteStale is when the claim of a chunk that is still being
+	// written can be taken over. It leaves a margin after uploadWriteTimeout
+	// so the old writer has stopped by then.
+	uploadWriteStale = 20 * time.Minute
+)
+
+// patchUpload appends one chunk. The chunk is written under a claim in the
+// database, so replicas sharing the session dir never write one upload at
+// the same time. A chunk with an Upload-Checksum is kept whole or not at
+// all; without one, whatever arrived before the connection dropped is kept
+// so the client can resume from there.
+func (h *Handler) patchUpload(ctx context.Context, w http.ResponseWriter, r *http.Request, s *dbrepo.UploadSession) {
+	if s.Status == dbrepo.UploadCompleting {
+		http.Error(w, "upload is being completed", http.StatusConflict)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/offset+octet-stream" && ct != "application/octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+	if offset != s.Offset {
+		http.Error(w, fmt.Sprintf("offset_mismatch: the upload is at offset %d", s.Offset), http.StatusConflict)
+		return
+	}
+	var want []byte
+	if v := r.Header.Get("Upload-Checksum"); v != "" {
+		algo, digest, _ := strings.Cut(v, " ")
+		want, err = base64.StdEncoding.DecodeString(digest)
+		if !strings.EqualFold(algo, "sha256") || err != nil || len(want) != sha256.Size {
+			http.Error(w, "Upload-Checksum must be \"sha256 <base64 digest>\"", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if offset == s.SizeBytes {
+		http.Error(w, "all bytes were received, complete the upload", http.StatusConflict)
+		return
+	}
+	limit := min(s.SizeBytes-offset, h.MaxUploadBytes)
+	if r.ContentLength > limit {
+		http.Error(w, fmt.Sprintf("chunk too large (at most %d bytes here)", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	writer := uuid.New().String()
+	claimed, err := h.Repo.ClaimUploadWrite(ctx, s.ID, offset, writer, uploadWriteStale)
+	if err != nil {
+		slog.ErrorContext(ctx, "claim upload write failed", "upload_id", s.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, "another chunk of this upload is being written or the offset changed, query the offset and retry", http.StatusConflict)
+		return
+	}
+	// the claim may be taken over after uploadWriteStale; stop reading well before
+	if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(uploadWriteTimeout)); err != nil {
+		slog.WarnContext(ctx, "set upload read deadline failed", "upload_id", s.ID, "error", err)
+	}
+
+	kept, status, msg := h.writeChunk(ctx, r, s.ID, offset, limit, want)
+
+	// the claim must be released even when the client went away
+	released, err := h.Repo.ReleaseUploadWrite(context.WithoutCancel(ctx), s.ID, writer, offset+kept)
+	if err != nil {
+		slog.ErrorContext(ctx, "release upload write failed", "upload_id", s.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !released {
+		http.Error(w, "upload changed while the chunk was written, query the offset and retry", http.StatusConflict)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset+kept, 10))
+	if status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeChunk writes the request body to the part file at offset and returns
+// how many bytes of it are kept. A chunk that failed comes with the HTTP
+// status and message to answer; status is 0 when it arrived whole.
+func (h *Handler) writeChunk(ctx context.Context, r *http.Request, id string, offset, limit int64, want []byte) (kept int64, status int, msg string) {
+	partPath := h.uploadPartPath(id)
+	part, err := os.OpenFile(partPath, os.O_WRONLY, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "open upload part failed", "upload_id", id, "error", err)
+		return 0, http.StatusInternalServerError, "internal error"
+	}
+	// drop anything past the recorded offset, e.g. a chunk that failed its checksum
+	if err := part.Truncate(offset); err != nil {
+		part.Close()
+		slog.ErrorContext(ctx, "truncate upload part failed", "upload_id", id, "error", err)
+		return 0, http.StatusInternalServerError, "internal error"
+	}
+
+	sum := sha256.New()
+	n, copyErr := io.Copy(io.NewOffsetWriter(part, offset), io.TeeReader(io.LimitReader(r.Body, limit), sum))
+	overflow := false
+	if copyErr == nil {
+		var probe [1]byte
+		if m, _ := r.Body.Read(probe[:]); m > 0 {
+			overflow = true
+		}
+	}
+	if err := part.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	switch {
+	case overflow:
+		os.Truncate(partPath, offset)
+		return 0, http.StatusRequestEntityTooLarge, fmt.Sprintf("chunk too large (at most %d bytes here)", limit)
+	case want != nil && copyErr != nil:
+		os.Truncate(partPath, offset)
+		slog.WarnContext(ctx, "upload chunk interrupted", "upload_id", id, "error", copyErr)
+		return 0, http.StatusBadRequest, "chunk was not received completely"
+	case want != nil && !bytes.Equal(sum.Sum(nil), want):
+		os.Truncate(partPath, offset)
+		return 0, http.StatusBadRequest, "checksum_mismatch: the chunk does not match Upload-Checksum"
+	case copyErr != nil:
+		slog.WarnContext(ctx, "upload chunk interrupted, kept partial chunk", "upload_id", id, "received", n, "error", copyErr)
+		return n, http.StatusBadRequest, "chunk was not received completely, resume from Upload-Offset"
+	}
+	return n, 0, ""
+}
+
+// completeUpload turns a fully received session into a document through
+// the normal upload pipeline.
+func (h *Handler) completeUpload(ctx context.Context, w http.ResponseWriter, r *http.Request, s *dbrepo.UploadSession) {
+	if !h.Jobs.Accepting() {
+		http.Error(w, "server is shutting down, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if s.Offset < s.SizeBytes {
+		http.Error(w, fmt.Sprintf("upload_incomplete: %d of %d bytes received", s.Offset, s.SizeBytes), http.StatusConflict)
+		return
+	}
+
+	// optional body: {"password": "..."} for an encrypted PDF, never stored
+	var secret struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&secret); err != nil && err != io.EOF {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var opts summarizer.Options
+	if err := json.Unmarshal(s.Options, &opts); err != nil {
+		slog.ErrorContext(ctx, "stored upload options unreadable", "upload_id", s.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	typ, ok := document.Lookup(s.Filename)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported file type (use %s)", document.Labels()), http.StatusBadRequest)
+		return
+	}
+
+	claimed, err := h.Repo.ClaimUploadSession(ctx, s.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "claim upload session failed", "upload_id", s.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, "upload is already being completed", http.StatusConflict)
+		return
+	}
+	pdfID := ""
+	// status writes must land even when the client goes away
+	dbCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if pdfID != "" {
+			return
+		}
+		if err := h.Repo.ReleaseUploadSession(dbCtx, s.ID); err != nil {
+			slog.ErrorContext(ctx, "release upload session failed", "upload_id", s.ID, "error", err)
+		}
+	}()
+
+	part, err := os.Open(h.uploadPartPath(s.ID))
+	if err != nil {
+		slog.ErrorContext(ctx, "open upload part failed", "upload_id", s.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer part.Close()
+
+	if s.Checksum != nil {
+		sum := sha256.New()
+		if _, err := io.Copy(sum, io.NewSectionReader(part, 0, s.SizeBytes)); err != nil {
+			slog.ErrorContext(ctx, "hash upload part failed", "upload_id", s.ID, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if hex.EncodeToString(sum.Sum(nil)) != *s.Checksum {
+			slog.WarnContext(ctx, "upload checksum mismatch", "upload_id", s.ID)
+			http.Error(w, "checksum_mismatch: the received file does not match the sha256 given when the upload started", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	pdfID = h.acceptUpload(ctx, w, typ, part, s.Filename, s.SizeBytes, opts, secret.Password)
+	if pdfID == "" {
+		return
+	}
+	if err := h.Repo.CompleteUploadSession(dbCtx, s.ID, pdfID); err != nil {
+		slog.ErrorContext(ctx, "complete upload session failed", "upload_id", s.ID, "error", err)
+	}
+	if err := os.Remove(h.uploadPartPath(s.ID)); err != nil {
+		slog.WarnContext(ctx, "remove upload part failed", "upload_id", s.ID, "error", err)
+	}
+}
+
+func (h *Handler) deleteUpload(ctx context.Context, w http.ResponseWriter, s *dbrepo.UploadSession) {
+	if s.Status == dbrepo.UploadCompleting {
+		http.Error(w, "upload is being completed", http.StatusConflict)
+		return
+	}
+	if err := h.Repo.DeleteUploadSession(ctx, s.ID); err != nil {
+		slog.ErrorContext(ctx, "delete upload session failed", "upload_id", s.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := os.Remove(h.uploadPartPath(s.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.WarnContext(ctx, "remove upload part failed", "upload_id", s.ID, "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SweepUploadSessions deletes expired upload sessions and their partial
+// files every interval until ctx is done.
+func (h *Handler) SweepUploadSessions(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		expired, err := h.Repo.DeleteExpiredUploadSessions(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "sweep upload sessions failed", "error", err)
+			continue
+		}
+		for _, id := range expired {
+			if err := os.Remove(h.uploadPartPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.WarnContext(ctx, "remove upload part failed", "upload_id", id, "error", err)
+			}
+		}
+		if len(expired) > 0 {
+			slog.InfoContext(ctx, "expired upload sessions removed", "count", len(expired))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrShuttingDown is returned by Go once Close has been called, and is the
+// cancellation cause of jobs aborted by a shutdown.
+var ErrShuttingDown = errors.New("job runner is shutting down")
+
+// ErrCancelled is the cancellation cause of a job stopped on user request.
+var ErrCancelled = errors.New("summary cancelled by user")
+
+// Runner tracks background jobs, such as summarizations, so the server can stop
+// accepting new work, drain the running ones on shutdown and cancel them
+// individually or all at once.
+type Runner struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	nextSeq uint64
+	running map[string]map[uint64]context.CancelCauseFunc
+}
+
+func NewRunner() *Runner {
+	return &Runner{running: make(map[string]map[uint64]context.CancelCauseFunc)}
+}
+
+// Go runs fn in a new goroutine, tracked under id (e.g. the pdf id). The context
+// passed to fn inherits the values of ctx and is cancelled by Cancel or
+// CancelAll; callers should pass a context that is not tied to a request.
+func (r *Runner) Go(ctx context.Context, id string, fn func(ctx context.Context)) error {
+	jobCtx, done, err := r.Track(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer done()
+		fn(jobCtx)
+	}()
+	return nil
+}
+
+// Track registers work that the caller runs itself (e.g. a synchronous
+// regenerate) so that Cancel and CancelAll reach it. done must be called
+// when the work has finished.
+func (r *Runner) Track(ctx context.Context, id string) (context.Context, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, nil, ErrShuttingDown
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	seq := r.nextSeq
+	r.nextSeq++
+	if r.running[id] == nil {
+		r.running[id] = make(map[uint64]context.CancelCauseFunc)
+	}
+	r.running[id][seq] = cancel
+
+	r.wg.Add(1)
+	return jobCtx, func() { r.done(id, seq) }, nil
+}
+
+func (r *Runner) done(id string, seq uint64) {
+	r.mu.Lock()
+	if cancel, ok := r.running[id][seq]; ok {
+		cancel(nil)
+		delete(r.running[id], seq)
+	}
+	if len(r.running[id]) == 0 {
+		delete(r.running, id)
+	}
+	r.mu.Unlock()
+	r.wg.Done()
+}
+
+// Cancel aborts every running job for id with the given cause and reports
+// whether there was one.
+func (r *Runner) Cancel(id string, cause error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cancel := range r.running[id] {
+		cancel(cause)
+	}
+	return len(r.running[id]) > 0
+}
+
+// CancelAll aborts every running job with the given cause.
+func (r *Runner) CancelAll(cause error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, jobs := range r.running {
+		for _, cancel := range jobs {
+			cancel(cause)
+		}
+	}
+}
+
+// Accepting reports whether new jobs can still be started.
+func (r *Runner) Accepting() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.closed
+}
+
+// Close stops the runner from accepting new jobs. Running jobs are not affected.
+func (r *Runner) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+}
+
+// Len returns the number of documents with a job that has not finished yet.
+func (r *Runner) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.running)
+}
+
+// Running returns the ids of jobs that have not finished yet.
+func (r *Runner) Running() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(r.running))
+	for id := range r.running {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Wait blocks until every running job has finished or ctx is done.
+// On timeout it returns the ids of the jobs that are still running.
+func (r *Runner) Wait(ctx context.Context) ([]string, error) {
+	finished := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil, nil
+	case <-ctx.Done():
+		return r.Running(), ctx.Err()
+	}
+}
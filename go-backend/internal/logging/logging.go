@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Level is the process-wide log level. It can be changed at runtime.
+var Level = new(slog.LevelVar)
+
+// Setup installs a JSON slog logger as the default logger. Lines written
+// through the standard log package go through it as well.
+func Setup(w io.Writer, level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	Level.Set(lvl)
+
+	handler := &contextHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: Level})}
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0)
+	return nil
+}
+
+// ParseLevel accepts debug, info, warn and error (case-insensitive).
+func ParseLevel(s string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, err
+	}
+	return lvl, nil
+}
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	pdfIDKey
+)
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithPdfID tags every log line written with ctx with the document id.
+func WithPdfID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, pdfIDKey, id)
+}
+
+func PdfID(ctx context.Context) string {
+	id, _ := ctx.Value(pdfIDKey).(string)
+	return id
+}
+
+// Detach returns a background context that keeps the request id, document
+// id and trace of ctx but not its deadline or cancellation.
+func Detach(ctx context.Context) context.Context {
+	out := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	if id := RequestID(ctx); id != "" {
+		out = WithRequestID(out, id)
+	}
+	if id := PdfID(ctx); id != "" {
+		out = WithPdfID(out, id)
+	}
+	return out
+}
+
+// contextHandler adds request_id, pdf_id and trace_id from the context to
+// every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := PdfID(ctx); id != "" {
+		r.AddAttrs(slog.String("pdf_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware assigns every request an id, taken from X-Request-ID when the
+// caller sent a sane one, and echoes it back in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
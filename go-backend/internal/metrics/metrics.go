@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pdfai"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled by the Go API, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	UploadBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of uploaded files in bytes.",
+		Buckets:   prometheus.ExponentialBuckets(16*1024, 4, 8), // 16KiB .. 256MiB
+	})
+
+	UploadsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_rejected_total",
+		Help:      "Uploads refused by content validation, by reason code.",
+	}, []string{"code"})
+
+	URLFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "url_fetches_total",
+		Help:      "Documents downloaded for POST /api/pdfs/from-url, by outcome.",
+	}, []string{"outcome"})
+
+	SummarizerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "summarizer_request_duration_seconds",
+		Help:      "Latency of calls to the summarizer service by mode and outcome.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 90, 120},
+	}, []string{"mode", "outcome"})
+
+	SummarizerBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "summarizer_breaker_state",
+		Help:      "Circuit breaker state per summarizer backend (0 closed, 1 half-open, 2 open).",
+	}, []string{"backend"})
+
+	SummarizerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "summarizer_errors_total",
+		Help:      "Failed calls to the summarizer service by mode.",
+	}, []string{"mode"})
+
+	SummarizerInvalidOutput = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "summarizer_invalid_output_total",
+		Help:      "Summarizer answers rejected by schema validation, by mode.",
+	}, []string{"mode"})
+)
+
+// ObserveSummarizer records the latency and outcome of one summarizer call.
+func ObserveSummarizer(mode string, started time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		SummarizerErrors.WithLabelValues(mode).Inc()
+	}
+	SummarizerDuration.WithLabelValues(mode, outcome).Observe(time.Since(started).Seconds())
+}
+
+// RegisterQueue exposes the number of summarization jobs currently running.
+func RegisterQueue(depth func() int) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "summary_jobs_running",
+		Help:      "Summarization jobs currently running in this process.",
+	}, func() float64 { return float64(depth()) }))
+}
+
+// RegisterDB exposes connection pool stats and pdf_summaries counts by status.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(
+		collectors.NewDBStatsCollector(db, namespace),
+		&summaryStatusCollector{db: db},
+	)
+}
+
+var summaryStatusDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "summaries"),
+	"Rows in pdf_summaries by status.",
+	[]string{"status"}, nil,
+)
+
+// summaryStatusCollector counts pdf_summaries rows at scrape time.
+type summaryStatusCollector struct {
+	db *sql.DB
+}
+
+func (c *summaryStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- summaryStatusDesc
+}
+
+func (c *summaryStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, `select status, count(*) from pdf_summaries group by status`)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(summaryStatusDesc, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			status string
+			count  int64
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			ch <- prometheus.NewInvalidMetric(summaryStatusDesc, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(summaryStatusDesc, prometheus.GaugeValue, float64(count), status)
+	}
+	if err := rows.Err(); err != nil {
+		ch <- prometheus.NewInvalidMetric(summaryStatusDesc, err)
+	}
+}
+
+// Handler serves the default registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware counts requests and measures latency. route maps a request to
+// a low-cardinality route label.
+func Middleware(next http.Handler, route func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		label := route(r)
+		HTTPRequests.WithLabelValues(label, r.Method, strconv.Itoa(rec.status)).Inc()
+		HTTPDuration.WithLabelValues(label, r.Method).Observe(time.Since(started).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,114 @@
+// Package storage keeps generated files, such as export archives, as blobs
+// addressed by a key.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by Open for keys that were never committed.
+var ErrNotFound = errors.New("blob not found")
+
+// Dir stores blobs as files below Root. Keys are slash-separated relative
+// paths such as "exports/1234.zip".
+type Dir struct {
+	Root string
+}
+
+func NewDir(root string) *Dir {
+	return &Dir{Root: root}
+}
+
+func (d *Dir) path(key string) (string, error) {
+	p := filepath.FromSlash(key)
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(d.Root, p), nil
+}
+
+// Create starts writing the blob key. The data only becomes visible under
+// key once Commit succeeds, so a reader never sees half a blob.
+func (d *Dir) Create(key string) (*Writer, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{f: f, path: path}, nil
+}
+
+// Open opens a committed blob for reading.
+func (d *Dir) Open(key string) (*os.File, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Remove deletes a blob; removing a missing blob is not an error.
+func (d *Dir) Remove(key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Writer is a blob being written. Exactly one of Commit or Abort must be
+// called.
+type Writer struct {
+	f    *os.File
+	path string
+	size int64
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Size is the number of bytes written so far.
+func (w *Writer) Size() int64 {
+	return w.size
+}
+
+// Commit flushes the blob and publishes it under its key.
+func (w *Writer) Commit() error {
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.path)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+	}
+	return err
+}
+
+// Abort discards the blob.
+func (w *Writer) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
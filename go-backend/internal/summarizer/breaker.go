@@ -0,0 +1,230 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned without calling the summarizer when its circuit
+// breaker is open or its concurrency limit stays exhausted.
+var ErrUnavailable = errors.New("summarizer_unavailable")
+
+// StatusError is a non-200 answer from the summarizer service.
+type StatusError struct {
+	Service    string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.Service, e.StatusCode)
+}
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+type BreakerSettings struct {
+	// FailureThreshold consecutive failures open the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probes through.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many probe calls may run while half-open.
+	HalfOpenRequests int
+	// MaxConcurrent bounds in-flight calls to one backend (the bulkhead).
+	MaxConcurrent int
+	// QueueTimeout is how long a call waits for a free slot before failing.
+	QueueTimeout time.Duration
+}
+
+// Breaker is a consecutive-failure circuit breaker combined with a
+// semaphore that bounds concurrent calls to one summarizer backend.
+type Breaker struct {
+	name     string
+	settings BreakerSettings
+	slots    chan struct{}
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+
+	// OnStateChange, when set, is called with the new state after every transition.
+	OnStateChange func(name string, state BreakerState)
+}
+
+func NewBreaker(name string, settings BreakerSettings) *Breaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+	if settings.MaxConcurrent <= 0 {
+		settings.MaxConcurrent = 1
+	}
+	return &Breaker{
+		name:     name,
+		settings: settings,
+		slots:    make(chan struct{}, settings.MaxConcurrent),
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refreshLocked(time.Now())
+	return b.state
+}
+
+// Do runs fn if the breaker lets the call through and a concurrency slot
+// frees up in time. Failures that point at an unhealthy backend (network
+// errors, timeouts, 5xx) count against the breaker; caller mistakes do not.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	if err := b.acquire(ctx); err != nil {
+		b.releaseProbe(probe)
+		return err
+	}
+	defer func() { <-b.slots }()
+
+	err = fn(ctx)
+	b.finish(probe, err)
+	return err
+}
+
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refreshLocked(time.Now())
+	switch b.state {
+	case StateOpen:
+		return false, fmt.Errorf("%w: %s circuit is open", ErrUnavailable, b.name)
+	case StateHalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return false, fmt.Errorf("%w: %s circuit is half-open", ErrUnavailable, b.name)
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b *Breaker) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	wait := b.settings.QueueTimeout
+	if wait <= 0 {
+		return fmt.Errorf("%w: %s is at its concurrency limit", ErrUnavailable, b.name)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w: %s is at its concurrency limit", ErrUnavailable, b.name)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Breaker) releaseProbe(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	b.probes--
+	b.mu.Unlock()
+}
+
+func (b *Breaker) finish(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probes--
+	}
+
+	// a call the caller gave up on says nothing about the backend
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	// any answer that is not a backend failure (including 4xx) proves the
+	// backend is reachable
+	failed := isBackendFailure(err)
+	switch {
+	case failed && b.state == StateHalfOpen:
+		b.setStateLocked(StateOpen, time.Now())
+	case failed:
+		b.failures++
+		if b.state == StateClosed && b.failures >= b.settings.FailureThreshold {
+			b.setStateLocked(StateOpen, time.Now())
+		}
+	case probe:
+		b.setStateLocked(StateClosed, time.Now())
+	default:
+		b.failures = 0
+	}
+}
+
+func (b *Breaker) refreshLocked(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setStateLocked(StateHalfOpen, now)
+	}
+}
+
+func (b *Breaker) setStateLocked(state BreakerState, now time.Time) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	b.failures = 0
+	if state == StateOpen {
+		b.openedAt = now
+	}
+	if b.OnStateChange != nil {
+		b.OnStateChange(b.name, state)
+	}
+}
+
+func isBackendFailure(err error) bool {
+	if err == nil || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrPasswordRequired) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500
+	}
+	return true
+}
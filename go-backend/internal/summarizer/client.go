@@ -2,75 +2,371 @@ package summarizer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"pdfai/go-backend/internal/metrics"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("pdfai/go-backend/internal/summarizer")
+
 type Response struct {
 	Summary       string `json:"summary"`
 	ProcessTimeMs int    `json:"process_time_ms"`
+	// Language the summary was written in, after resolving "auto".
+	Language string `json:"language"`
+	// Takeaways are the key conclusions of the document.
+	Takeaways []string `json:"takeaways"`
+	// Structured is the validated JSON of a structured summary; Summary then
+	// holds its plain-text rendering.
+	Structured json.RawMessage `json:"-"`
+}
+
+// ErrPasswordRequired is returned for encrypted PDFs when no password was
+// given or the given one is wrong.
+var ErrPasswordRequired = errors.New("password_required")
+
+// Input is the document the summarizer reads: a stored PDF, which the
+// service extracts itself, or the text the API already extracted from
+// another document type.
+type Input struct {
+	FilePath string
+	Text     string
+	// Password opens an encrypted PDF. It is only ever sent to the
+	// summarizer, never stored or logged.
+	Password string
+}
+
+func (in Input) addTo(payload map[string]interface{}) {
+	if in.Text != "" {
+		payload["text"] = in.Text
+		return
+	}
+	payload["file_path"] = in.FilePath
+	if in.Password != "" {
+		payload["password"] = in.Password
+	}
+}
+
+// statusError turns a non-200 answer into an error. The service answers 422
+// with a "password_required: ..." detail for encrypted PDFs it cannot open.
+func statusError(service string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		var body struct {
+			Detail string `json:"detail"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err == nil {
+			if reason, ok := strings.CutPrefix(body.Detail, ErrPasswordRequired.Error()+": "); ok {
+				return fmt.Errorf("%w: %s", ErrPasswordRequired, reason)
+			}
+		}
+	}
+	return &StatusError{Service: service, StatusCode: resp.StatusCode}
 }
 
 type Client struct {
 	BaseURL string
 	Client  *http.Client
+
+	// Breakers guard each backend endpoint: "summarize", "takeaways",
+	// "translate" and "preview".
+	Breakers map[string]*Breaker
 }
 
-func NewClient() *Client {
-	url := os.Getenv("SUMMARIZER_URL")
-	if url == "" {
-		url = "http://localhost:8000/summarize"
+func NewClient(baseURL string, timeout time.Duration, breaker BreakerSettings) *Client {
+	breakers := make(map[string]*Breaker)
+	for _, name := range []string{"summarize", "takeaways", "translate", "preview"} {
+		b := NewBreaker(name, breaker)
+		b.OnStateChange = func(name string, state BreakerState) {
+			metrics.SummarizerBreakerState.WithLabelValues(name).Set(float64(state))
+			slog.Warn("summarizer circuit breaker changed state", "backend", name, "state", state.String())
+		}
+		breakers[name] = b
 	}
+
 	return &Client{
-		BaseURL: url,
-		Client:  &http.Client{Timeout: 120 * time.Second},
+		BaseURL: baseURL,
+		Client: &http.Client{
+			Timeout: timeout,
+			// injects W3C traceparent headers into outgoing requests
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		Breakers: breakers,
 	}
 }
 
-func (c *Client) Summarize(filePath string, mode string) (*Response, error) {
-	if mode == "" {
-		mode = "detailed"
+// BreakerStates reports the current state of every backend breaker.
+func (c *Client) BreakerStates() map[string]string {
+	states := make(map[string]string, len(c.Breakers))
+	for name, b := range c.Breakers {
+		states[name] = b.State().String()
 	}
+	return states
+}
 
-	body, err := json.Marshal(map[string]string{
-		"file_path": filePath,
-		"mode":      mode,
-	})
+// logCall writes one line per summarizer call with its outcome and duration.
+func logCall(ctx context.Context, op string, started time.Time, err error, attrs ...any) {
+	attrs = append(attrs, "op", op, "duration_ms", time.Since(started).Milliseconds())
+	if err != nil {
+		slog.ErrorContext(ctx, "summarizer call failed", append(attrs, "outcome", "error", "error", err)...)
+		return
+	}
+	slog.InfoContext(ctx, "summarizer call finished", append(attrs, "outcome", "success")...)
+}
+
+func endSpan(span trace.Span, err error) {
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *Client) Summarize(ctx context.Context, in Input, opts Options) (out *Response, err error) {
+	if err := opts.Normalize(); err != nil {
 		return nil, err
 	}
+	mode := opts.Mode
 
-	req, err := http.NewRequest(http.MethodPost, c.BaseURL, bytes.NewReader(body))
+	ctx, span := tracer.Start(ctx, "summarizer.Summarize", trace.WithAttributes(
+		attribute.String("summarizer.mode", mode),
+		attribute.String("summarizer.language", opts.Language),
+	))
+	started := time.Now()
+	defer func() {
+		metrics.ObserveSummarizer(mode, started, err)
+		logCall(ctx, "summarize", started, err, "mode", mode, "language", opts.Language)
+		endSpan(span, err)
+	}()
+
+	payload := map[string]interface{}{
+		"mode":          mode,
+		"language":      opts.Language,
+		"language_name": LanguageName(opts.Language),
+		"max_words":     opts.MaxWords,
+		"prompt":        opts.Prompt,
+	}
+	in.addTo(payload)
+	if mode == ModeStructured {
+		payload["schema"] = json.RawMessage(StructuredSchema)
+	}
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.Client.Do(req)
+	attempts := 1
+	if mode == ModeStructured {
+		attempts = structuredAttempts
+	}
+
+	var invalid error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		out, err = c.summarizeOnce(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+		if mode != ModeStructured {
+			return out, nil
+		}
+
+		parsed, err := ParseStructured(out.Summary)
+		if err == nil {
+			out.Structured, err = json.Marshal(parsed)
+			if err != nil {
+				return nil, err
+			}
+			out.Summary = parsed.Text()
+			out.Takeaways = parsed.Takeaways
+			span.SetAttributes(attribute.Int("summarizer.attempts", attempt))
+			return out, nil
+		}
+		invalid = err
+		metrics.SummarizerInvalidOutput.WithLabelValues(mode).Inc()
+		slog.WarnContext(ctx, "structured summary failed validation", "attempt", attempt, "error", err)
+	}
+	return nil, fmt.Errorf("%w after %d attempts: %v", ErrInvalidOutput, attempts, invalid)
+}
+
+func (c *Client) summarizeOnce(ctx context.Context, body []byte) (out *Response, err error) {
+	err = c.Breakers["summarize"].Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return statusError("summarizer", resp)
+		}
+
+		out = &Response{}
+		return json.NewDecoder(resp.Body).Decode(out)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return out, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("summarizer returned status %d", resp.StatusCode)
+// Takeaways asks the summarizer service for the key takeaways of a stored
+// document, without summarizing it again.
+func (c *Client) Takeaways(ctx context.Context, in Input, language string) (out []string, err error) {
+	ctx, span := tracer.Start(ctx, "summarizer.Takeaways", trace.WithAttributes(
+		attribute.String("summarizer.language", language),
+	))
+	started := time.Now()
+	defer func() {
+		logCall(ctx, "takeaways", started, err, "language", language)
+		endSpan(span, err)
+	}()
+
+	takeawaysURL := strings.Replace(c.BaseURL, "/summarize", "/takeaways", 1)
+
+	payload := map[string]interface{}{
+		"language":      language,
+		"language_name": LanguageName(language),
+	}
+	in.addTo(payload)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Takeaways []string `json:"takeaways"`
 	}
+	err = c.Breakers["takeaways"].Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, takeawaysURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-	var out Response
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return statusError("takeaways service", resp)
+		}
+
+		return json.NewDecoder(resp.Body).Decode(&result)
+	})
+	if err != nil {
 		return nil, err
 	}
-	return &out, nil
+	return result.Takeaways, nil
+}
+
+// Translate asks the summarizer service to translate a summary into the
+// given language, keeping its structure (paragraphs, bullets).
+func (c *Client) Translate(ctx context.Context, text string, language string) (out string, err error) {
+	ctx, span := tracer.Start(ctx, "summarizer.Translate", trace.WithAttributes(
+		attribute.String("summarizer.language", language),
+	))
+	started := time.Now()
+	defer func() {
+		logCall(ctx, "translate", started, err, "language", language)
+		endSpan(span, err)
+	}()
+
+	translateURL := strings.Replace(c.BaseURL, "/summarize", "/translate", 1)
+
+	body, err := json.Marshal(map[string]string{
+		"text":          text,
+		"language":      language,
+		"language_name": LanguageName(language),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	err = c.Breakers["translate"].Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, translateURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return &StatusError{Service: "translation service", StatusCode: resp.StatusCode}
+		}
+
+		return json.NewDecoder(resp.Body).Decode(&result)
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(result.Text) == "" {
+		return "", fmt.Errorf("translation service returned an empty text")
+	}
+	return result.Text, nil
 }
 
-func (c *Client) GetPreview(filePath string) (string, error) {
+func (c *Client) Ping(ctx context.Context) error {
+	healthURL := strings.Replace(c.BaseURL, "/summarize", "/health", 1)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("summarizer health returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetPreview returns the start of the text of a PDF. password may be empty
+// for PDFs that are not encrypted.
+func (c *Client) GetPreview(ctx context.Context, filePath string, password string) (text string, err error) {
+	ctx, span := tracer.Start(ctx, "summarizer.GetPreview")
+	started := time.Now()
+	defer func() {
+		logCall(ctx, "preview", started, err)
+		endSpan(span, err)
+	}()
+
 	previewURL := strings.Replace(c.BaseURL, "/summarize", "/preview-pdf", 1)
 	
 	// Create multipart form
@@ -91,62 +387,42 @@ func (c *Client) GetPreview(filePath string) (string, error) {
 	if _, err := io.Copy(part, file); err != nil {
 		return "", err
 	}
-	
-	if err := writer.Close(); err != nil {
-		return "", err
-	}
-	
-	req, err := http.NewRequest(http.MethodPost, previewURL, &buf)
-	if err != nil {
-		return "", err
+
+	if password != "" {
+		if err := writer.WriteField("password", password); err != nil {
+			return "", err
+		}
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 	
-	resp, err := c.Client.Do(req)
-	if err != nil {
+	if err := writer.Close(); err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("preview service returned status %d", resp.StatusCode)
-	}
 	
 	var result struct {
 		PreviewText string `json:"preview_text"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
-	
-	return result.PreviewText, nil
-}
+	err = c.Breakers["preview"].Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, previewURL, &buf)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
 
-func (c *Client) GeneratePDF(summary string) ([]byte, error) {
-	pdfURL := strings.Replace(c.BaseURL, "/summarize", "/download-summary-pdf", 1)
-	
-	body, err := json.Marshal(map[string]string{
-		"summary": summary,
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return statusError("preview service", resp)
+		}
+
+		return json.NewDecoder(resp.Body).Decode(&result)
 	})
 	if err != nil {
-		return nil, err
-	}
-	
-	req, err := http.NewRequest(http.MethodPost, pdfURL, bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("PDF generation service returned status %d", resp.StatusCode)
+		return "", err
 	}
-	
-	return io.ReadAll(resp.Body)
+
+	return result.PreviewText, nil
 }
@@ -0,0 +1,94 @@
+package summarizer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Modes are the modes the summarizer service has built-in instructions for.
+// Languages lists the languages with hand-written instructions; any other
+// language code is passed to the model by name.
+var (
+	Modes     = []string{"short", "detailed", "bullet", ModeStructured}
+	Languages = []string{"auto", "id", "en"}
+)
+
+// languageCodeRe matches lowercase BCP 47 style codes such as "fr" or "pt-br".
+var languageCodeRe = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// ValidLanguage reports whether code is "auto" or looks like a language code.
+func ValidLanguage(code string) bool {
+	return code == "auto" || languageCodeRe.MatchString(code)
+}
+
+const (
+	DefaultMode     = "detailed"
+	DefaultLanguage = "auto"
+	MaxWordsLimit   = 2000
+)
+
+// Options control how a document is summarized. They are stored with the
+// summary so a result can be reproduced.
+type Options struct {
+	Mode string `json:"mode"`
+	// Language of the summary, e.g. "id", "en" or "fr"; "auto" follows the
+	// language of the document.
+	Language string `json:"language"`
+	// MaxWords caps the summary length; 0 keeps the mode's default length.
+	MaxWords int `json:"max_words,omitempty"`
+	// Prompt is the resolved instruction of a custom template mode. It is
+	// empty for the built-in modes.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// IsBuiltinMode reports whether mode is handled by the summarizer service
+// itself rather than by a summary template.
+func IsBuiltinMode(mode string) bool {
+	return slices.Contains(Modes, strings.ToLower(strings.TrimSpace(mode)))
+}
+
+// Normalize fills in defaults and rejects unsupported values. A mode that is
+// not built in must look like a template name; whether the template exists
+// is up to the caller.
+func (o *Options) Normalize() error {
+	o.Mode = strings.ToLower(strings.TrimSpace(o.Mode))
+	o.Language = strings.ToLower(strings.TrimSpace(o.Language))
+
+	if o.Mode == "" {
+		o.Mode = DefaultMode
+	}
+	if o.Language == "" {
+		o.Language = DefaultLanguage
+	}
+
+	if !slices.Contains(Modes, o.Mode) && !templateNameRe.MatchString(o.Mode) {
+		return fmt.Errorf("unsupported mode %q (supported: %s or a template name)", o.Mode, strings.Join(Modes, ", "))
+	}
+	if !ValidLanguage(o.Language) {
+		return fmt.Errorf("unsupported language %q (use auto or a language code such as %s)", o.Language, strings.Join(Languages[1:], ", "))
+	}
+	if o.MaxWords < 0 || o.MaxWords > MaxWordsLimit {
+		return fmt.Errorf("max_words must be between 0 and %d (0 for the mode's default)", MaxWordsLimit)
+	}
+	return nil
+}
+
+// OptionsFromForm reads summary options from form values (multipart uploads).
+// Custom template modes still need to be resolved by the caller.
+func OptionsFromForm(get func(string) string) (Options, error) {
+	opts := Options{
+		Mode:     get("mode"),
+		Language: get("language"),
+	}
+	if v := strings.TrimSpace(get("max_words")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("max_words must be a number")
+		}
+		opts.MaxWords = n
+	}
+	return opts, nil
+}
@@ -0,0 +1,169 @@
+package summarizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// schema is the subset of JSON Schema that StructuredSchema is written in.
+// Compiling a schema with any other keyword fails, so the schema cannot use
+// a rule that validate would silently skip.
+type schema struct {
+	Type                 string             `json:"type"`
+	Description          string             `json:"description"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MinLength            *int               `json:"minLength"`
+	Pattern              string             `json:"pattern"`
+	Enum                 []any              `json:"enum"`
+
+	pattern *regexp.Regexp
+}
+
+// mustCompileSchema parses a JSON Schema document and panics if it uses
+// anything validate does not implement.
+func mustCompileSchema(doc string) *schema {
+	var s schema
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		panic(fmt.Sprintf("summarizer: invalid schema: %v", err))
+	}
+	s.compile()
+	return &s
+}
+
+func (s *schema) compile() {
+	switch s.Type {
+	case "", "object", "array", "string", "number", "integer", "boolean", "null":
+	default:
+		panic(fmt.Sprintf("summarizer: unsupported schema type %q", s.Type))
+	}
+	if s.Pattern != "" {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	for _, p := range s.Properties {
+		p.compile()
+	}
+	if s.Items != nil {
+		s.Items.compile()
+	}
+}
+
+// validate checks a value decoded by encoding/json against s and returns
+// every violation, naming it by its path below the document root.
+func (s *schema) validate(v any, path string) []error {
+	if len(s.Enum) > 0 {
+		if !slices.ContainsFunc(s.Enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+			return []error{fmt.Errorf("%s %s is not one of %s", path, describe(v), describeEnum(s.Enum))}
+		}
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		return []error{fmt.Errorf("%s must be %s", path, article(s.Type))}
+	}
+
+	var errs []error
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s is required", join(path, name)))
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if p, ok := s.Properties[name]; ok {
+				errs = append(errs, p.validate(v[name], join(path, name))...)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				errs = append(errs, fmt.Errorf("%s is not allowed", join(path, name)))
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			errs = append(errs, fmt.Errorf("%s must have at least %d item(s)", path, *s.MinItems))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				errs = append(errs, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		if s.MinLength != nil && utf8.RuneCountInString(v) < *s.MinLength {
+			errs = append(errs, fmt.Errorf("%s must be at least %d character(s) long", path, *s.MinLength))
+		} else if s.pattern != nil && !s.pattern.MatchString(v) {
+			errs = append(errs, fmt.Errorf("%s must match %s", path, s.Pattern))
+		}
+	}
+	return errs
+}
+
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func article(typ string) string {
+	switch typ {
+	case "object", "array", "integer":
+		return "an " + typ
+	case "null":
+		return "null"
+	}
+	return "a " + typ
+}
+
+func describe(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func describeEnum(values []any) string {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = describe(v)
+		if s, ok := v.(string); ok {
+			names[i] = s
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,154 @@
+package summarizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ModeStructured asks for a JSON summary matching StructuredSchema instead
+// of free text.
+const ModeStructured = "structured"
+
+// structuredAttempts is how many times a structured summary is requested
+// before invalid model output is reported as an error.
+const structuredAttempts = 3
+
+// ErrInvalidOutput is returned when the summarizer kept answering with
+// output that does not match the expected schema.
+var ErrInvalidOutput = errors.New("summarizer returned invalid structured output")
+
+// StructuredSchema is the JSON Schema a structured summary must satisfy. It
+// is sent to the summarizer service with every structured request and
+// enforced by ParseStructured, so it is the one definition of what a
+// structured summary may contain.
+const StructuredSchema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["title", "abstract", "key_points", "takeaways", "entities", "action_items"],
+  "properties": {
+    "title": {"type": "string", "minLength": 1, "pattern": "\\S"},
+    "abstract": {"type": "string", "minLength": 1, "pattern": "\\S"},
+    "key_points": {"type": "array", "minItems": 1, "items": {"type": "string", "minLength": 1, "pattern": "\\S"}},
+    "takeaways": {"type": "array", "items": {"type": "string", "minLength": 1, "pattern": "\\S"}},
+    "entities": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["name", "type"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1, "pattern": "\\S"},
+          "type": {"enum": ["person", "organization", "location", "date", "product", "other"]}
+        }
+      }
+    },
+    "action_items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["description"],
+        "properties": {
+          "description": {"type": "string", "minLength": 1, "pattern": "\\S"},
+          "owner": {"type": "string"},
+          "due": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+// Structured is a summary in machine-readable form.
+type Structured struct {
+	Title       string       `json:"title"`
+	Abstract    string       `json:"abstract"`
+	KeyPoints   []string     `json:"key_points"`
+	Takeaways   []string     `json:"takeaways"`
+	Entities    []Entity     `json:"entities"`
+	ActionItems []ActionItem `json:"action_items"`
+}
+
+type Entity struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type ActionItem struct {
+	Description string `json:"description"`
+	Owner       string `json:"owner,omitempty"`
+	Due         string `json:"due,omitempty"`
+}
+
+var structuredSchema = mustCompileSchema(StructuredSchema)
+
+// ParseStructured decodes model output and checks it against
+// StructuredSchema. A surrounding Markdown code fence is tolerated; every
+// schema violation is reported.
+func ParseStructured(raw string) (*Structured, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "```") {
+		raw = strings.TrimPrefix(raw, "```json")
+		raw = strings.TrimPrefix(raw, "```")
+		raw = strings.TrimSuffix(strings.TrimSpace(raw), "```")
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, fmt.Errorf("not a JSON object: %w", err)
+	}
+	if _, ok := v.(map[string]any); !ok {
+		return nil, errors.New("not a JSON object")
+	}
+	if errs := structuredSchema.validate(v, ""); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var s Structured
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Text renders a structured summary as plain text, used as summary_text so
+// listings and downloads keep working.
+func (s *Structured) Text() string {
+	var b strings.Builder
+	b.WriteString(s.Title)
+	b.WriteString("\n\n")
+	b.WriteString(s.Abstract)
+	b.WriteString("\n")
+
+	list := func(heading string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		b.WriteString("\n" + heading + ":\n")
+		for _, item := range items {
+			b.WriteString("- " + item + "\n")
+		}
+	}
+	list("Key points", s.KeyPoints)
+	list("Takeaways", s.Takeaways)
+
+	actions := make([]string, 0, len(s.ActionItems))
+	for _, a := range s.ActionItems {
+		line := a.Description
+		var extra []string
+		if a.Owner != "" {
+			extra = append(extra, "owner: "+a.Owner)
+		}
+		if a.Due != "" {
+			extra = append(extra, "due: "+a.Due)
+		}
+		if len(extra) > 0 {
+			line += " (" + strings.Join(extra, ", ") + ")"
+		}
+		actions = append(actions, line)
+	}
+	list("Action items", actions)
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,90 @@
+package summarizer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+var templateNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidateTemplateName checks that name can be used as a mode: a short
+// lowercase slug that does not shadow a built-in mode.
+func ValidateTemplateName(name string) error {
+	if !templateNameRe.MatchString(name) {
+		return fmt.Errorf("template name must be 1-64 lowercase letters, digits, '-' or '_'")
+	}
+	if slices.Contains(Modes, name) {
+		return fmt.Errorf("template name %q is a built-in mode", name)
+	}
+	return nil
+}
+
+// PromptVars are the variables available inside a prompt template, e.g.
+// "Summarize in {{.LanguageName}} in at most {{.MaxWords}} words."
+type PromptVars struct {
+	Language     string
+	LanguageName string
+	MaxWords     int
+}
+
+var languageNames = map[string]string{
+	"auto":  "the same language as the document",
+	"ar":    "Arabic",
+	"de":    "German",
+	"en":    "English",
+	"es":    "Spanish",
+	"fr":    "French",
+	"hi":    "Hindi",
+	"id":    "Indonesian",
+	"it":    "Italian",
+	"ja":    "Japanese",
+	"jv":    "Javanese",
+	"ko":    "Korean",
+	"ms":    "Malay",
+	"nl":    "Dutch",
+	"pt":    "Portuguese",
+	"pt-br": "Brazilian Portuguese",
+	"ru":    "Russian",
+	"su":    "Sundanese",
+	"th":    "Thai",
+	"tl":    "Tagalog",
+	"tr":    "Turkish",
+	"vi":    "Vietnamese",
+	"zh":    "Chinese",
+}
+
+// LanguageName returns a human-readable name for a language code, suitable
+// for use inside a prompt. Unknown codes are returned as they are; the model
+// understands most of them.
+func LanguageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// RenderPrompt fills a prompt template with the given options.
+func RenderPrompt(prompt string, opts Options) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(prompt)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, PromptVars{
+		Language:     opts.Language,
+		LanguageName: LanguageName(opts.Language),
+		MaxWords:     opts.MaxWords,
+	}); err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	out := strings.TrimSpace(b.String())
+	if out == "" {
+		return "", fmt.Errorf("prompt template renders to an empty prompt")
+	}
+	return out, nil
+}
@@ -0,0 +1,3 @@
+-- replica that is running the summary, so a cancel request can reach it
+alter table pdf_summaries add column if not exists owner text;
+alter table pdf_summaries add column if not exists cancelled_at timestamptz;
@@ -0,0 +1,4 @@
+-- options the summary was requested with, so results can be reproduced
+alter table pdf_summaries add column if not exists mode text;
+alter table pdf_summaries add column if not exists language text;
+alter table pdf_summaries add column if not exists options jsonb;
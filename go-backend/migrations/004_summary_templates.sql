@@ -0,0 +1,11 @@
+-- custom summary modes; name is what clients pass as "mode"
+create table if not exists summary_templates (
+    id uuid primary key,
+    name text not null unique,
+    description text not null default '',
+    prompt text not null,
+    default_language text,
+    default_max_words integer,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
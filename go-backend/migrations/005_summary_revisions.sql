@@ -0,0 +1,30 @@
+-- every summary text ever produced for a document; pdf_summaries.current_rev
+-- points at the one shown by default
+create table if not exists summary_revisions (
+    id uuid primary key,
+    pdf_id uuid not null references pdf_files(id) on delete cascade,
+    rev integer not null,
+    parent_rev integer,
+    kind text not null, -- generated | translated
+    summary_text text not null,
+    mode text,
+    language text,
+    options jsonb,
+    process_time_ms integer,
+    created_at timestamptz not null default now(),
+    unique (pdf_id, rev)
+);
+
+alter table pdf_summaries add column if not exists current_rev integer;
+
+-- summaries written before revisions existed become revision 1
+insert into summary_revisions (id, pdf_id, rev, kind, summary_text, mode, language, options, process_time_ms, created_at)
+select gen_random_uuid(), s.pdf_id, 1, 'generated', s.summary_text, s.mode, s.language, s.options, s.process_time_ms, s.updated_at
+from pdf_summaries s
+where s.summary_text is not null
+  and not exists (select 1 from summary_revisions r where r.pdf_id = s.pdf_id);
+
+update pdf_summaries s
+set current_rev = 1
+where s.current_rev is null
+  and exists (select 1 from summary_revisions r where r.pdf_id = s.pdf_id and r.rev = 1);
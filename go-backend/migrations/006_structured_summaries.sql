@@ -0,0 +1,2 @@
+-- machine-readable summary (mode "structured"), validated by the Go API
+alter table summary_revisions add column if not exists structured jsonb;
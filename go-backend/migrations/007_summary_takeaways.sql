@@ -0,0 +1,2 @@
+-- key takeaways of a revision, as a JSON array of strings
+alter table summary_revisions add column if not exists takeaways jsonb;
@@ -0,0 +1,2 @@
+-- who wrote an edited revision
+alter table summary_revisions add column if not exists author text;
@@ -0,0 +1,16 @@
+-- bulk exports of many summaries into one ZIP archive
+create table if not exists export_jobs (
+    id uuid primary key,
+    status text not null, -- pending | running | success | failed
+    format text not null,
+    include_originals boolean not null default false,
+    created_from timestamptz,
+    created_to timestamptz,
+    document_count integer,
+    blob_key text,
+    size_bytes bigint,
+    error_message text,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    finished_at timestamptz
+);
@@ -0,0 +1,17 @@
+-- resumable uploads: the file arrives in chunks and becomes a pdf_files row
+-- when the session is completed
+create table if not exists upload_sessions (
+    id uuid primary key,
+    status text not null, -- active | completing | completed
+    filename text not null,
+    size_bytes bigint not null,
+    offset_bytes bigint not null default 0,
+    checksum text, -- hex SHA-256 of the whole file, checked on completion
+    options jsonb,
+    pdf_id uuid references pdf_files(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    expires_at timestamptz not null
+);
+
+create index if not exists upload_sessions_expires_at_idx on upload_sessions (expires_at);
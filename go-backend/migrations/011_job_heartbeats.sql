@@ -0,0 +1,5 @@
+-- running jobs are touched regularly by their replica; a job whose
+-- heartbeat stopped belongs to a replica that died without draining
+alter table pdf_summaries add column if not exists heartbeat_at timestamptz;
+alter table export_jobs add column if not exists owner text;
+alter table export_jobs add column if not exists heartbeat_at timestamptz;
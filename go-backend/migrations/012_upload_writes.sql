@@ -0,0 +1,3 @@
+-- a chunk is written under a claim so replicas sharing the session dir
+-- never write the same upload at once; status 'writing' holds the claim
+alter table upload_sessions add column if not exists writer uuid;